@@ -172,7 +172,10 @@ var rootCmd = &cobra.Command{
 				os.Exit(1)
 			}
 
-			avl.PrintDotGraph(tr, &b)
+			if err := avl.PrintDotGraph(tr, &b); err != nil {
+				c.Println("Error: failed to print dot graph;", err.Error())
+				os.Exit(1)
+			}
 
 			fmt.Fprint(os.Stdout, b.String())
 		}
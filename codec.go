@@ -0,0 +1,275 @@
+package avl
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+var (
+	InvalidEncodedNodeError  = NewWrapError("invalid encoded node")
+	UnknownCodecVersionError = NewWrapError("unknown codec version")
+)
+
+// BinaryNodeCodecVersion is the version of encoded node by BinaryNodeCodec.
+const BinaryNodeCodecVersion byte = 1
+
+const (
+	binaryNodeCodecLeftFlag byte = 1 << iota
+	binaryNodeCodecRightFlag
+)
+
+var binaryNodeCodecLeafFlags = [2]byte{binaryNodeCodecLeftFlag, binaryNodeCodecRightFlag}
+
+// NodeCodec encodes Node to bytes and decodes the bytes to Node. It is for the
+// NodePool, which stores node to the external storage.
+type NodeCodec interface {
+	Encode(Node) ([]byte, error)
+	Decode([]byte) (Node, error)
+}
+
+// PayloadCodec is the hook for the user's value of node. NodeCodec stores only
+// the basic properties of node, key, height and the leaf keys; the other
+// values of node are stored by PayloadCodec as payload.
+type PayloadCodec interface {
+	// EncodePayload returns the payload of node. nil payload is allowed.
+	EncodePayload(Node) ([]byte, error)
+
+	// DecodePayload returns new Node from BaseNode, which has the basic
+	// properties and payload.
+	DecodePayload(BaseNode) (Node, error)
+}
+
+// BaseNode is the Node, which has only the basic properties and the encoded
+// payload. The decoded node from BinaryNodeCodec is BaseNode, if PayloadCodec
+// is not given.
+type BaseNode struct {
+	key      []byte
+	height   int16
+	leftKey  []byte
+	rightKey []byte
+	payload  []byte
+}
+
+func NewBaseNode(key []byte, height int16, leftKey, rightKey, payload []byte) BaseNode {
+	return BaseNode{
+		key:      key,
+		height:   height,
+		leftKey:  leftKey,
+		rightKey: rightKey,
+		payload:  payload,
+	}
+}
+
+func (bn BaseNode) Key() []byte {
+	return bn.key
+}
+
+func (bn BaseNode) Height() int16 {
+	return bn.height
+}
+
+func (bn BaseNode) LeftKey() []byte {
+	return bn.leftKey
+}
+
+func (bn BaseNode) RightKey() []byte {
+	return bn.rightKey
+}
+
+// Payload returns the encoded user's value.
+func (bn BaseNode) Payload() []byte {
+	return bn.payload
+}
+
+// WithPayload returns new BaseNode with the given payload.
+func (bn BaseNode) WithPayload(payload []byte) BaseNode {
+	bn.payload = payload
+
+	return bn
+}
+
+// BinaryNodeCodec is the compact binary NodeCodec. The encoded node is,
+//
+//	version(1 byte) | flags(1 byte) | key | height | left key | right key | payload
+//
+// flags tells which leaf exists; the leaf key of missing leaf is omitted. key,
+// the leaf keys and payload are prefixed by it's length in uvarint and height
+// is varint.
+//
+// Decode does not copy the input; the keys and payload of the decoded node
+// refer the input bytes, so the input should not be modified after decoding.
+type BinaryNodeCodec struct {
+	payload PayloadCodec
+}
+
+// NewBinaryNodeCodec returns new BinaryNodeCodec. If PayloadCodec is nil, the
+// payload of BaseNode is stored as it is and the node is decoded to BaseNode.
+func NewBinaryNodeCodec(payload PayloadCodec) BinaryNodeCodec {
+	return BinaryNodeCodec{payload: payload}
+}
+
+func (bc BinaryNodeCodec) Encode(node Node) ([]byte, error) {
+	var payload []byte
+	if bc.payload != nil {
+		p, err := bc.payload.EncodePayload(node)
+		if err != nil {
+			return nil, err
+		}
+		payload = p
+	} else if bn, ok := node.(BaseNode); ok {
+		payload = bn.Payload()
+	}
+
+	var flags byte
+	size := 2 + binary.MaxVarintLen64*5 + len(node.Key()) + len(payload)
+	for i, key := range [][]byte{node.LeftKey(), node.RightKey()} {
+		if key != nil {
+			flags |= binaryNodeCodecLeafFlags[i]
+			size += len(key)
+		}
+	}
+
+	b := make([]byte, 2, size)
+	b[0] = BinaryNodeCodecVersion
+	b[1] = flags
+
+	b = appendBytes(b, node.Key())
+	b = appendVarint(b, int64(node.Height()))
+
+	for _, key := range [][]byte{node.LeftKey(), node.RightKey()} {
+		if key != nil {
+			b = appendBytes(b, key)
+		}
+	}
+
+	return appendBytes(b, payload), nil
+}
+
+func (bc BinaryNodeCodec) Decode(b []byte) (Node, error) {
+	bn, err := bc.decode(b)
+	if err != nil {
+		return nil, err
+	}
+
+	if bc.payload == nil {
+		return bn, nil
+	}
+
+	return bc.payload.DecodePayload(bn)
+}
+
+func (bc BinaryNodeCodec) decode(b []byte) (BaseNode, error) {
+	if len(b) < 2 {
+		return BaseNode{}, InvalidEncodedNodeError.Wrapf("too short; length=%d", len(b))
+	} else if b[0] != BinaryNodeCodecVersion {
+		return BaseNode{}, UnknownCodecVersionError.Wrapf("version=%d", b[0])
+	}
+
+	flags := b[1]
+	if flags&^(binaryNodeCodecLeftFlag|binaryNodeCodecRightFlag) != 0 {
+		return BaseNode{}, InvalidEncodedNodeError.Wrapf("unknown flags; flags=%08b", flags)
+	}
+
+	var bn BaseNode
+	r := byteReader{b: b, i: 2}
+
+	bn.key = r.bytes()
+
+	height := r.varint()
+	if height < 0 || height > math.MaxInt16 {
+		return BaseNode{}, InvalidEncodedNodeError.Wrapf("invalid height; height=%d", height)
+	}
+	bn.height = int16(height)
+
+	if flags&binaryNodeCodecLeftFlag != 0 {
+		bn.leftKey = r.bytes()
+	}
+	if flags&binaryNodeCodecRightFlag != 0 {
+		bn.rightKey = r.bytes()
+	}
+
+	if payload := r.bytes(); len(payload) > 0 {
+		bn.payload = payload
+	}
+
+	if r.err != nil {
+		return BaseNode{}, r.err
+	} else if r.i != len(b) {
+		return BaseNode{}, InvalidEncodedNodeError.Wrapf("trailing bytes; length=%d", len(b)-r.i)
+	}
+
+	return bn, nil
+}
+
+func appendBytes(b, a []byte) []byte {
+	var l [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(l[:], uint64(len(a)))
+
+	return append(append(b, l[:n]...), a...)
+}
+
+func appendVarint(b []byte, i int64) []byte {
+	var l [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(l[:], i)
+
+	return append(b, l[:n]...)
+}
+
+// byteReader reads the encoded values from bytes. After the first error, the
+// next reads return the zero values.
+type byteReader struct {
+	b   []byte
+	i   int
+	err error
+}
+
+func (br *byteReader) uvarint() uint64 {
+	if br.err != nil {
+		return 0
+	}
+
+	v, n := binary.Uvarint(br.b[br.i:])
+	if n <= 0 {
+		br.err = InvalidEncodedNodeError.Wrapf("invalid uvarint at %d", br.i)
+		return 0
+	}
+	br.i += n
+
+	return v
+}
+
+func (br *byteReader) varint() int64 {
+	if br.err != nil {
+		return 0
+	}
+
+	v, n := binary.Varint(br.b[br.i:])
+	if n <= 0 {
+		br.err = InvalidEncodedNodeError.Wrapf("invalid varint at %d", br.i)
+		return 0
+	}
+	br.i += n
+
+	return v
+}
+
+// bytes reads the length prefixed bytes. The returned bytes is the slice of
+// input, the capacity is limited, so appending to it does not overwrite the
+// input.
+func (br *byteReader) bytes() []byte {
+	l := br.uvarint()
+	if br.err != nil {
+		return nil
+	}
+
+	if l > uint64(len(br.b)-br.i) {
+		br.err = InvalidEncodedNodeError.Wrapf("not enough bytes at %d; length=%d", br.i, l)
+		return nil
+	}
+
+	end := br.i + int(l)
+	v := br.b[br.i:end:end]
+	br.i = end
+
+	return v
+}
@@ -0,0 +1,162 @@
+package avl
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/xerrors"
+)
+
+type testValueNode struct {
+	BaseNode
+	value int64
+}
+
+type testValuePayloadCodec struct{}
+
+func (testValuePayloadCodec) EncodePayload(node Node) ([]byte, error) {
+	vn, ok := node.(testValueNode)
+	if !ok {
+		return nil, xerrors.Errorf("not testValueNode; %T", node)
+	}
+
+	b := make([]byte, binary.MaxVarintLen64)
+	return b[:binary.PutVarint(b, vn.value)], nil
+}
+
+func (testValuePayloadCodec) DecodePayload(bn BaseNode) (Node, error) {
+	v, n := binary.Varint(bn.Payload())
+	if n <= 0 {
+		return nil, xerrors.Errorf("invalid payload")
+	}
+
+	return testValueNode{BaseNode: bn.WithPayload(nil), value: v}, nil
+}
+
+type testBinaryNodeCodec struct {
+	suite.Suite
+}
+
+func (t *testBinaryNodeCodec) TestRoundTrip() {
+	codec := NewBinaryNodeCodec(nil)
+
+	cases := []struct {
+		name  string
+		left  []byte
+		right []byte
+	}{
+		{name: "no leaf"},
+		{name: "left", left: nodeIntKey(10)},
+		{name: "right", right: nodeIntKey(30)},
+		{name: "both", left: nodeIntKey(10), right: nodeIntKey(30)},
+	}
+
+	for _, c := range cases {
+		node := &ExampleNode{key: nodeIntKey(20), height: 3, left: c.left, right: c.right}
+
+		b, err := codec.Encode(node)
+		t.NoError(err, c.name)
+		t.Equal(BinaryNodeCodecVersion, b[0], c.name)
+
+		decoded, err := codec.Decode(b)
+		t.NoError(err, c.name)
+		t.IsType(BaseNode{}, decoded, c.name)
+		t.Equal(node.Key(), decoded.Key(), c.name)
+		t.Equal(node.Height(), decoded.Height(), c.name)
+		t.Equal(node.LeftKey(), decoded.LeftKey(), c.name)
+		t.Equal(node.RightKey(), decoded.RightKey(), c.name)
+		t.Nil(decoded.(BaseNode).Payload(), c.name)
+	}
+}
+
+func (t *testBinaryNodeCodec) TestPayload() {
+	codec := NewBinaryNodeCodec(testValuePayloadCodec{})
+
+	node := testValueNode{
+		BaseNode: NewBaseNode(nodeIntKey(20), 1, nodeIntKey(10), nil, nil),
+		value:    -33,
+	}
+
+	b, err := codec.Encode(node)
+	t.NoError(err)
+
+	decoded, err := codec.Decode(b)
+	t.NoError(err)
+	t.Equal(node, decoded)
+
+	_, err = codec.Encode(&ExampleNode{key: nodeIntKey(20)})
+	t.Error(err)
+}
+
+func (t *testBinaryNodeCodec) TestBaseNodePayload() {
+	codec := NewBinaryNodeCodec(nil)
+
+	node := NewBaseNode(nodeIntKey(20), 1, nil, nodeIntKey(30), []byte("payload"))
+
+	b, err := codec.Encode(node)
+	t.NoError(err)
+
+	decoded, err := codec.Decode(b)
+	t.NoError(err)
+	t.Equal(node, decoded)
+}
+
+func (t *testBinaryNodeCodec) TestZeroCopy() {
+	codec := NewBinaryNodeCodec(nil)
+
+	b, err := codec.Encode(&ExampleNode{key: nodeIntKey(20), left: nodeIntKey(10)})
+	t.NoError(err)
+
+	decoded, err := codec.Decode(b)
+	t.NoError(err)
+
+	// NOTE the decoded key refers the input
+	key := decoded.Key()
+	key[0] = 'x'
+	t.Equal(key, decoded.Key())
+	t.Contains(string(b), string(key))
+
+	// NOTE appending to the decoded key does not overwrite the input
+	left := append(decoded.LeftKey(), 'y')
+	t.Equal(nodeIntKey(10), left[:len(left)-1])
+
+	again, err := codec.Decode(b)
+	t.NoError(err)
+	t.Equal(nodeIntKey(10), again.LeftKey())
+}
+
+func (t *testBinaryNodeCodec) TestInvalid() {
+	codec := NewBinaryNodeCodec(nil)
+
+	b, err := codec.Encode(&ExampleNode{key: nodeIntKey(20), height: 1, right: nodeIntKey(30)})
+	t.NoError(err)
+
+	{ // unknown version
+		c := append([]byte{}, b...)
+		c[0] = 0xff
+		_, err := codec.Decode(c)
+		t.True(xerrors.Is(err, UnknownCodecVersionError))
+	}
+
+	{ // unknown flags
+		c := append([]byte{}, b...)
+		c[1] = 0xf0
+		_, err := codec.Decode(c)
+		t.True(xerrors.Is(err, InvalidEncodedNodeError))
+	}
+
+	for i := 0; i < len(b); i++ { // truncated
+		_, err := codec.Decode(b[:i])
+		t.True(xerrors.Is(err, InvalidEncodedNodeError), "length=%d", i)
+	}
+
+	{ // trailing bytes
+		_, err := codec.Decode(append(append([]byte{}, b...), 0))
+		t.True(xerrors.Is(err, InvalidEncodedNodeError))
+	}
+}
+
+func TestBinaryNodeCodec(t *testing.T) {
+	suite.Run(t, new(testBinaryNodeCodec))
+}
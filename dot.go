@@ -6,7 +6,12 @@ import (
 	"os"
 )
 
-func printDotGraphNode(w io.Writer, tr *Tree, node Node) {
+func printDotGraphNode(w io.Writer, tr *Tree, node Node, visitor *nodeVisitor) error {
+	if err := visitor.enter(node); err != nil {
+		return err
+	}
+	defer visitor.exit(node)
+
 	fmt.Fprintf(w, `  "%s" [label="%s (%d)"];
 `,
 		string(node.Key()),
@@ -30,7 +35,7 @@ func printDotGraphNode(w io.Writer, tr *Tree, node Node) {
 			string(node.Key())+"1",
 		)
 
-		return
+		return nil
 	}
 
 	if node.LeftKey() != nil {
@@ -54,8 +59,9 @@ func printDotGraphNode(w io.Writer, tr *Tree, node Node) {
 			)
 		}
 
-		left, _ := tr.NodePool().Get(node.LeftKey())
-		printDotGraphNode(w, tr, left)
+		if err := printDotGraphLeaf(w, tr, node.LeftKey(), visitor); err != nil {
+			return err
+		}
 	}
 
 	if node.RightKey() != nil {
@@ -79,16 +85,35 @@ func printDotGraphNode(w io.Writer, tr *Tree, node Node) {
 			string(node.RightKey()),
 		)
 
-		right, _ := tr.NodePool().Get(node.RightKey())
-		printDotGraphNode(w, tr, right)
+		if err := printDotGraphLeaf(w, tr, node.RightKey(), visitor); err != nil {
+			return err
+		}
 	}
+
+	return nil
+}
+
+func printDotGraphLeaf(w io.Writer, tr *Tree, key []byte, visitor *nodeVisitor) error {
+	if err := visitor.check(key); err != nil {
+		return err
+	}
+
+	leaf, err := tr.NodePool().Get(key)
+	if err != nil {
+		return err
+	} else if leaf == nil {
+		return NodeNotFoundInPoolError.Wrapf("leaf key=%x", key)
+	}
+
+	return printDotGraphNode(w, tr, leaf, visitor)
 }
 
 // PrintDotGraph will print dot graph source of Tree. With the printed output,
 // the dot graph image can be easily made:
 // 	$ cat <dot graph source> | dot -Tpng -o/tmp/d.png
-// `dot` is the utility of graphviz.
-func PrintDotGraph(tr *Tree, w io.Writer) {
+// `dot` is the utility of graphviz. If the leaf refers it's ancestor,
+// PrintDotGraph stops with CyclicNodeError.
+func PrintDotGraph(tr *Tree, w io.Writer) error {
 	if w == nil {
 		w = os.Stdout
 	}
@@ -97,8 +122,8 @@ func PrintDotGraph(tr *Tree, w io.Writer) {
 	defer fmt.Fprintln(w, "}")
 
 	if tr.root == nil {
-		return
+		return nil
 	}
 
-	printDotGraphNode(w, tr, tr.root)
+	return printDotGraphNode(w, tr, tr.root, newPathNodeVisitor())
 }
@@ -30,7 +30,7 @@ func ExamplePrintDotGraph() {
 		return
 	}
 
-	avl.PrintDotGraph(tree, os.Stdout)
+	_ = avl.PrintDotGraph(tree, os.Stdout)
 	// Output:
 	// graph graphname {
 	//   "003" [label="003 (3)"];
@@ -0,0 +1,189 @@
+package avl
+
+import (
+	"github.com/rs/zerolog"
+)
+
+var (
+	NotDeletableNodePoolError = NewWrapError("NodePool does not support Delete")
+)
+
+// AddressedNode is the node, which is stored in NodePool by the other key than
+// Key(), like the node of content-addressed NodePool. Address() returns the key
+// for NodePool.Get().
+type AddressedNode interface {
+	Node
+	Address() []byte
+}
+
+// nodeAddress returns the key, which node is stored by in NodePool.
+func nodeAddress(node Node) []byte {
+	if an, ok := node.(AddressedNode); ok {
+		return an.Address()
+	}
+
+	return node.Key()
+}
+
+// GarbageCollector finds and removes the unreachable nodes in NodePool. The
+// reachable nodes are marked from the given roots, and then the nodes, which
+// are not marked, are swept. The nodes are marked by the key in NodePool, so
+// if NodePool stores node by the other key than Key(), the node should
+// implement AddressedNode.
+type GarbageCollector struct {
+	*Logger
+	np NodePool
+}
+
+// NewGarbageCollector returns new GarbageCollector.
+func NewGarbageCollector(np NodePool) GarbageCollector {
+	return GarbageCollector{
+		Logger: NewLogger(func(c zerolog.Context) zerolog.Context {
+			return c.Str("module", "avl_garbage_collector")
+		}),
+		np: np,
+	}
+}
+
+// Mark collects the keys of every node, which is reachable from one or more
+// roots. Each node is fetched from NodePool only once.
+func (gc GarbageCollector) Mark(rootKeys ...[]byte) (map[string]struct{}, error) {
+	var roots []Node
+	for _, key := range rootKeys {
+		node, err := gc.np.Get(key)
+		if err != nil {
+			return nil, err
+		} else if node == nil {
+			return nil, NodeNotFoundInPoolError.Wrapf("root key=%x", key)
+		}
+
+		roots = append(roots, node)
+	}
+
+	return gc.mark(roots)
+}
+
+// mark collects the keys of every node from the roots. The leaves are fetched
+// by LeftKey() and RightKey(), but the marked keys are the addresses of nodes
+// by nodeAddress(), so it can be compared with the nodes from
+// NodePool.Traverse(). The leaves of same depth are fetched at once by
+// GetNodes().
+func (gc GarbageCollector) mark(roots []Node) (map[string]struct{}, error) {
+	marked := map[string]struct{}{}
+	fetched := map[string]struct{}{}
+
+	nodes := make([]Node, len(roots))
+	copy(nodes, roots)
+
+	for len(nodes) > 0 {
+		var keys [][]byte
+		for _, node := range nodes {
+			marked[string(nodeAddress(node))] = struct{}{}
+
+			for _, key := range [][]byte{node.LeftKey(), node.RightKey()} {
+				if key == nil {
+					continue
+				} else if _, found := fetched[string(key)]; found {
+					continue
+				}
+
+				fetched[string(key)] = struct{}{}
+				keys = append(keys, key)
+			}
+		}
+
+		if len(keys) < 1 {
+			break
+		}
+
+		leaves, err := GetNodes(gc.np, keys)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range leaves {
+			if leaves[i] == nil {
+				return nil, NodeNotFoundInPoolError.Wrapf("key=%x", keys[i])
+			}
+		}
+
+		nodes = leaves
+	}
+
+	gc.Log().Debug().Int("roots", len(roots)).Int("marked", len(marked)).Msg("nodes marked")
+
+	return marked, nil
+}
+
+// Orphans returns the keys in NodePool of the nodes, which are not reachable
+// from the roots.
+func (gc GarbageCollector) Orphans(rootKeys ...[]byte) ([][]byte, error) {
+	marked, err := gc.Mark(rootKeys...)
+	if err != nil {
+		return nil, err
+	}
+
+	return gc.sweep(marked)
+}
+
+// orphans acts like Orphans(), but it starts from the root nodes.
+func (gc GarbageCollector) orphans(roots ...Node) ([][]byte, error) {
+	marked, err := gc.mark(roots)
+	if err != nil {
+		return nil, err
+	}
+
+	return gc.sweep(marked)
+}
+
+// Collect removes the nodes, which are not reachable from the roots, and
+// returns the keys of removed nodes. NodePool should implement
+// DeletableNodePool.
+func (gc GarbageCollector) Collect(rootKeys ...[]byte) ([][]byte, error) {
+	dp, ok := gc.np.(DeletableNodePool)
+	if !ok {
+		return nil, NotDeletableNodePoolError.Wrapf("type=%T", gc.np)
+	}
+
+	orphans, err := gc.Orphans(rootKeys...)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range orphans {
+		if err := dp.Delete(key); err != nil {
+			return nil, err
+		}
+	}
+
+	gc.Log().Debug().Int("removed", len(orphans)).Msg("orphans removed")
+
+	return orphans, nil
+}
+
+// sweep returns the keys of the nodes, which are not marked. If NodePool is
+// CountableNodePool and the number of nodes is same with the marked, the
+// traversing is skipped.
+func (gc GarbageCollector) sweep(marked map[string]struct{}) ([][]byte, error) {
+	if cp, ok := gc.np.(CountableNodePool); ok {
+		if n, err := cp.Len(); err != nil {
+			return nil, err
+		} else if n == len(marked) {
+			return nil, nil
+		}
+	}
+
+	var orphans [][]byte
+	if err := gc.np.Traverse(func(node Node) (bool, error) {
+		address := nodeAddress(node)
+		if _, found := marked[string(address)]; !found {
+			orphans = append(orphans, address)
+		}
+
+		return true, nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return orphans, nil
+}
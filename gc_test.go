@@ -0,0 +1,117 @@
+package avl
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/xerrors"
+)
+
+type testGarbageCollector struct {
+	suite.Suite
+}
+
+func (t *testGarbageCollector) newNodePool() *MapNodePool {
+	np := NewMapNodePool(nil)
+	for k, v := range map[int]shape{
+		// NOTE tree of 100
+		100: {height: 2, left: 50, right: 150},
+		50:  {height: 1, left: 30},
+		30:  {height: 0},
+		150: {height: 0},
+		// NOTE tree of 500; shares 150
+		500: {height: 2, left: 150, right: 600},
+		600: {height: 1, right: 700},
+		700: {height: 0},
+		// NOTE orphans
+		800: {height: 1, left: 810},
+		810: {height: 0},
+		900: {height: 0},
+	} {
+		node := newExampleNode(k)
+		node.height = v.height
+		if v.left > 0 {
+			node.left = nodeIntKey(v.left)
+		}
+		if v.right > 0 {
+			node.right = nodeIntKey(v.right)
+		}
+
+		_ = np.Set(node)
+	}
+
+	return np
+}
+
+func (t *testGarbageCollector) keys(keys [][]byte) []int {
+	var ks []int
+	for _, k := range keys {
+		ks = append(ks, parseNodeIntKey(k))
+	}
+	sort.Ints(ks)
+
+	return ks
+}
+
+func (t *testGarbageCollector) TestMark() {
+	gc := NewGarbageCollector(t.newNodePool())
+
+	marked, err := gc.Mark(nodeIntKey(100), nodeIntKey(500))
+	t.NoError(err)
+	t.Equal(7, len(marked))
+
+	for _, k := range []int{100, 50, 30, 150, 500, 600, 700} {
+		_, found := marked[string(nodeIntKey(k))]
+		t.True(found, "key=%d", k)
+	}
+}
+
+func (t *testGarbageCollector) TestOrphans() {
+	np := t.newNodePool()
+	gc := NewGarbageCollector(np)
+
+	orphans, err := gc.Orphans(nodeIntKey(100), nodeIntKey(500))
+	t.NoError(err)
+	t.Equal([]int{800, 810, 900}, t.keys(orphans))
+
+	orphans, err = gc.Orphans(nodeIntKey(100))
+	t.NoError(err)
+	t.Equal([]int{500, 600, 700, 800, 810, 900}, t.keys(orphans))
+
+	// NOTE nothing removed
+	t.Equal(10, len(np.m))
+}
+
+func (t *testGarbageCollector) TestCollect() {
+	np := t.newNodePool()
+	gc := NewGarbageCollector(np)
+
+	removed, err := gc.Collect(nodeIntKey(500))
+	t.NoError(err)
+	t.Equal([]int{30, 50, 100, 800, 810, 900}, t.keys(removed))
+	t.Equal(4, len(np.m))
+
+	tr, err := NewTree(nodeIntKey(500), np)
+	t.NoError(err)
+	t.NoError(tr.IsValid())
+}
+
+func (t *testGarbageCollector) TestMissingNode() {
+	np := t.newNodePool()
+	_ = np.Delete(nodeIntKey(30))
+
+	_, err := NewGarbageCollector(np).Orphans(nodeIntKey(100))
+	t.True(xerrors.Is(err, NodeNotFoundInPoolError))
+}
+
+func (t *testGarbageCollector) TestNotDeletable() {
+	np := struct{ NodePool }{t.newNodePool()}
+
+	_, err := NewGarbageCollector(np).Collect(nodeIntKey(100))
+	t.True(xerrors.Is(err, NotDeletableNodePoolError))
+}
+
+func TestGarbageCollector(t *testing.T) {
+	suite.Run(t, new(testGarbageCollector))
+}
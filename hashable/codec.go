@@ -0,0 +1,145 @@
+package hashable
+
+import (
+	"encoding/binary"
+
+	"github.com/spikeekips/avl"
+)
+
+// PayloadCodec is the hook for the user's value of HashableNode. Like
+// avl.PayloadCodec, the hashes are stored by BinaryNodeCodec, so PayloadCodec
+// needs to store only the user's value.
+type PayloadCodec interface {
+	EncodePayload(HashableNode) ([]byte, error)
+	DecodePayload(BaseNode) (HashableNode, error)
+}
+
+// BaseNode is the HashableNode, which is decoded by BinaryNodeCodec.
+type BaseNode struct {
+	avl.BaseNode
+	hash      []byte
+	leftHash  []byte
+	rightHash []byte
+	valueHash []byte
+}
+
+func NewBaseNode(node avl.BaseNode, hash, leftHash, rightHash, valueHash []byte) BaseNode {
+	return BaseNode{
+		BaseNode:  node,
+		hash:      hash,
+		leftHash:  leftHash,
+		rightHash: rightHash,
+		valueHash: valueHash,
+	}
+}
+
+func (bn BaseNode) Hash() []byte {
+	return bn.hash
+}
+
+func (bn BaseNode) LeftHash() []byte {
+	return bn.leftHash
+}
+
+func (bn BaseNode) RightHash() []byte {
+	return bn.rightHash
+}
+
+func (bn BaseNode) ValueHash() []byte {
+	return bn.valueHash
+}
+
+// BinaryNodeCodec is the avl.BinaryNodeCodec for HashableNode. The hash, leaf
+// hashes and value hash are stored in the payload of avl.BinaryNodeCodec
+// before the user's payload,
+//
+//	hash | left hash | right hash | value hash | user's payload
+//
+// Each hash is prefixed by it's length in uvarint; the empty hash is decoded
+// to nil. Like avl.BinaryNodeCodec, the decoded hashes refer the input bytes.
+type BinaryNodeCodec struct {
+	avl.BinaryNodeCodec
+}
+
+// NewBinaryNodeCodec returns new BinaryNodeCodec. If PayloadCodec is nil, the
+// payload of BaseNode is stored as it is and the node is decoded to BaseNode.
+func NewBinaryNodeCodec(payload PayloadCodec) BinaryNodeCodec {
+	return BinaryNodeCodec{
+		BinaryNodeCodec: avl.NewBinaryNodeCodec(hashablePayloadCodec{payload: payload}),
+	}
+}
+
+type hashablePayloadCodec struct {
+	payload PayloadCodec
+}
+
+func (hc hashablePayloadCodec) EncodePayload(node avl.Node) ([]byte, error) {
+	hn, ok := node.(HashableNode)
+	if !ok {
+		return nil, NotHashableNodeError.Wrapf("key=%x type=%T", node.Key(), node)
+	}
+
+	var payload []byte
+	if hc.payload != nil {
+		p, err := hc.payload.EncodePayload(hn)
+		if err != nil {
+			return nil, err
+		}
+		payload = p
+	} else if bn, ok := hn.(BaseNode); ok {
+		payload = bn.Payload()
+	}
+
+	hashes := [][]byte{hn.Hash(), hn.LeftHash(), hn.RightHash(), hn.ValueHash()}
+
+	size := len(payload) + binary.MaxVarintLen64*len(hashes)
+	for _, h := range hashes {
+		size += len(h)
+	}
+
+	b := make([]byte, 0, size)
+
+	var l [binary.MaxVarintLen64]byte
+	for _, h := range hashes {
+		n := binary.PutUvarint(l[:], uint64(len(h)))
+		b = append(append(b, l[:n]...), h...)
+	}
+
+	return append(b, payload...), nil
+}
+
+func (hc hashablePayloadCodec) DecodePayload(node avl.BaseNode) (avl.Node, error) {
+	b := node.Payload()
+
+	var i int
+	hashes := make([][]byte, 4)
+	for j := range hashes {
+		l, n := binary.Uvarint(b[i:])
+		if n <= 0 {
+			return nil, avl.InvalidEncodedNodeError.Wrapf("invalid hash length; key=%x", node.Key())
+		}
+		i += n
+
+		if l > uint64(len(b)-i) {
+			return nil, avl.InvalidEncodedNodeError.Wrapf("not enough bytes for hash; key=%x", node.Key())
+		} else if l < 1 {
+			continue
+		}
+
+		end := i + int(l)
+		hashes[j] = b[i:end:end]
+		i = end
+	}
+
+	var payload []byte
+	if i < len(b) {
+		payload = b[i:]
+	}
+
+	bn := NewBaseNode(node.WithPayload(payload), hashes[0], hashes[1], hashes[2], hashes[3])
+	if hc.payload == nil {
+		return bn, nil
+	}
+
+	return hc.payload.DecodePayload(bn)
+}
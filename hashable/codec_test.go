@@ -0,0 +1,120 @@
+package hashable
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/spikeekips/avl"
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/xerrors"
+)
+
+type testValueHashableNode struct {
+	BaseNode
+	value int64
+}
+
+type testValuePayloadCodec struct{}
+
+func (testValuePayloadCodec) EncodePayload(node HashableNode) ([]byte, error) {
+	var value int64
+	switch t := node.(type) {
+	case *ExampleHashableMutableNode:
+		value = int64(t.value)
+	case testValueHashableNode:
+		value = t.value
+	default:
+		return nil, xerrors.Errorf("unknown node; %T", node)
+	}
+
+	b := make([]byte, binary.MaxVarintLen64)
+	return b[:binary.PutVarint(b, value)], nil
+}
+
+func (testValuePayloadCodec) DecodePayload(bn BaseNode) (HashableNode, error) {
+	v, n := binary.Varint(bn.Payload())
+	if n <= 0 {
+		return nil, xerrors.Errorf("invalid payload")
+	}
+
+	return testValueHashableNode{BaseNode: bn, value: v}, nil
+}
+
+type testBinaryNodeCodec struct {
+	suite.Suite
+	prover ExampleProver
+}
+
+func (t *testBinaryNodeCodec) TestRoundTripTree() {
+	tr, err := newTestHashedTree([]int{5, 3, 8, 1, 4, 7, 9, 2, 6}, t.prover.GenerateNodeHash)
+	t.NoError(err)
+
+	codec := NewBinaryNodeCodec(nil)
+
+	np := avl.NewMapNodePool(nil)
+	t.NoError(tr.Traverse(func(node avl.Node) (bool, error) {
+		b, err := codec.Encode(node)
+		if err != nil {
+			return false, err
+		}
+
+		decoded, err := codec.Decode(b)
+		if err != nil {
+			return false, err
+		}
+
+		hn := node.(HashableNode)
+		dn := decoded.(BaseNode)
+		t.Equal(hn.Hash(), dn.Hash())
+		t.Equal(hn.LeftHash(), dn.LeftHash())
+		t.Equal(hn.RightHash(), dn.RightHash())
+		t.Equal(hn.ValueHash(), dn.ValueHash())
+
+		return true, np.Set(decoded)
+	}))
+
+	decodedTree, err := avl.NewTree(tr.Root().Key(), np)
+	t.NoError(err)
+	t.NoError(decodedTree.IsValid())
+	t.NoError(IsValidTreeHash(decodedTree, t.prover.GenerateNodeHash))
+}
+
+func (t *testBinaryNodeCodec) TestPayload() {
+	codec := NewBinaryNodeCodec(testValuePayloadCodec{})
+
+	node := &ExampleHashableMutableNode{key: testKey(1), value: 33}
+	h, err := t.prover.GenerateNodeHash(node)
+	t.NoError(err)
+	t.NoError(node.SetHash(h))
+
+	b, err := codec.Encode(node)
+	t.NoError(err)
+
+	decoded, err := codec.Decode(b)
+	t.NoError(err)
+
+	vn, ok := decoded.(testValueHashableNode)
+	t.True(ok)
+	t.Equal(int64(33), vn.value)
+	t.Equal(node.Hash(), vn.Hash())
+	t.Nil(vn.LeftHash())
+	t.Nil(vn.RightHash())
+	t.Equal(node.ValueHash(), vn.ValueHash())
+}
+
+func (t *testBinaryNodeCodec) TestNotHashable() {
+	_, err := NewBinaryNodeCodec(nil).Encode(avl.NewBaseNode(testKey(1), 0, nil, nil, nil))
+	t.True(xerrors.Is(err, NotHashableNodeError))
+}
+
+func (t *testBinaryNodeCodec) TestInvalidPayload() {
+	b, err := avl.NewBinaryNodeCodec(nil).Encode(avl.NewBaseNode(testKey(1), 0, nil, nil, []byte{0xff}))
+	t.NoError(err)
+
+	_, err = NewBinaryNodeCodec(nil).Decode(b)
+	t.True(xerrors.Is(err, avl.InvalidEncodedNodeError))
+}
+
+func TestBinaryNodeCodec(t *testing.T) {
+	suite.Run(t, new(testBinaryNodeCodec))
+}
@@ -1,9 +1,9 @@
 package hashable
 
 import (
-	"bytes"
 	"crypto/sha256"
-	"sort"
+	"encoding/binary"
+	"hash"
 )
 
 type ExampleProver struct {
@@ -23,121 +23,54 @@ func (ep ExampleProver) generateNodeHash(
 	key []byte, height int16, valueHash, leftHash, rightHash []byte,
 ) ([]byte, error) {
 	h := sha256.New()
-	_, _ = h.Write(key)
+	writeHashPart(h, key)
 	_, _ = h.Write(int16ToBytes(height))
-	if valueHash != nil {
-		_, _ = h.Write(valueHash)
-	}
-	if leftHash != nil {
-		_, _ = h.Write(leftHash)
-	}
-	if rightHash != nil {
-		_, _ = h.Write(rightHash)
-	}
+	writeHashPart(h, valueHash)
+	writeHashPart(h, leftHash)
+	writeHashPart(h, rightHash)
 
 	return h.Sum(nil), nil
 }
 
-func (ep ExampleProver) Proof(node HashableNode, parents []HashableNode) (Proof, error) {
-	// NOTE sort by height; lower height will be first item
-	sort.Slice(parents, func(i, j int) bool { return parents[i].Height() < parents[j].Height() })
+// writeHashPart writes the presence marker and the length before b, so the
+// left hash can not be moved to the right hash without changing the node hash.
+func writeHashPart(h hash.Hash, b []byte) {
+	if b == nil {
+		_, _ = h.Write([]byte{0})
 
-	var parentProofs []ExampleParentProof
-	for _, node := range parents {
-		p := node.(HashableNode)
-		parentProofs = append(parentProofs, ExampleParentProof{
-			key:       p.Key(),
-			height:    p.Height(),
-			hash:      p.Hash(),
-			leftHash:  p.LeftHash(),
-			rightHash: p.RightHash(),
-			valueHash: p.ValueHash(),
-		})
+		return
 	}
 
-	n := node.(HashableNode)
-	return ExampleProof{
-		node: ExampleParentProof{
-			key:       n.Key(),
-			height:    n.Height(),
-			hash:      n.Hash(),
-			leftHash:  n.LeftHash(),
-			rightHash: n.RightHash(),
-			valueHash: n.ValueHash(),
-		},
-		parents: parentProofs,
-	}, nil
-}
-
-func (ep ExampleProver) proveProofNode(pr ExampleParentProof) error {
-	nodeHash, err := ep.generateNodeHash(
-		pr.key,
-		pr.height,
-		pr.valueHash,
-		pr.leftHash,
-		pr.rightHash,
-	)
-	if err != nil {
-		return err
-	}
+	l := make([]byte, 5)
+	l[0] = 1
+	binary.BigEndian.PutUint32(l[1:], uint32(len(b)))
 
-	if !bytes.Equal(pr.hash, nodeHash) {
-		return InvalidProofError.Wrapf(
-			"node hash not match: proof.hash=%v != generated=%v",
-			pr.hash,
-			nodeHash,
-		)
-	}
+	_, _ = h.Write(l)
+	_, _ = h.Write(b)
+}
 
-	return nil
+func (ep ExampleProver) Proof(node HashableNode, parents []HashableNode) (Proof, error) {
+	return NewPathProof(node, parents)
 }
 
 func (ep ExampleProver) Prove(proof Proof, rootHash []byte) error {
-	pr := proof.(ExampleProof)
-
-	// check node itself: node hash
-	if err := ep.proveProofNode(pr.node); err != nil {
-		return err
-	}
-
-	var prRootHash []byte
-	if len(pr.parents) < 1 {
-		prRootHash = pr.node.hash
-	} else {
-		prRootHash = pr.parents[len(pr.parents)-1].hash
-	}
-
-	// check with root hash with node hash
-	if !bytes.Equal(prRootHash, rootHash) {
-		return InvalidProofError.Wrapf(
-			"top node hash not match with root hash: topnode.hash=%v != root.hash=%v",
-			prRootHash,
-			rootHash,
-		)
-	}
-
-	if len(pr.parents) < 1 {
-		return nil
+	pr, ok := proof.(PathProof)
+	if !ok {
+		return InvalidProofError.Wrapf("not PathProof; %T", proof)
 	}
 
-	// check parents hashes
-	var leaf ExampleParentProof = pr.node
-	for _, p := range pr.parents {
-		if !bytes.Equal(leaf.hash, p.leftHash) && !bytes.Equal(leaf.hash, p.rightHash) {
-			return InvalidProofError.Wrapf(
-				"node hash not match with leaves of 1st parents: leaf.hash=%v != (parents.leftHash=%v, parent.rightHash=%v)",
-				pr.node.hash,
-				p.leftHash,
-				p.rightHash,
-			)
-		}
+	return pr.Prove(rootHash, ep.GenerateNodeHash)
+}
 
-		if err := ep.proveProofNode(p); err != nil {
-			return err
-		}
+func (ep ExampleProver) ProveAbsence(key []byte, path []HashableNode) (Proof, error) {
+	return NewAbsenceProof(key, path)
+}
 
-		leaf = p
+func (ep ExampleProver) VerifyAbsence(proof Proof, key, rootHash []byte) error {
+	pr, ok := proof.(AbsenceProof)
+	if !ok {
+		return InvalidProofError.Wrapf("not AbsenceProof; %T", proof)
 	}
 
-	return nil
+	return pr.Prove(key, rootHash, ep.GenerateNodeHash)
 }
@@ -0,0 +1,143 @@
+package hashable
+
+import (
+	"sync"
+
+	"github.com/spikeekips/avl"
+	"golang.org/x/xerrors"
+)
+
+// HashAddressedNode is the node from HashNodePool. HashAddressedNode refers
+// it's leaves by hash, so LeftKey() and RightKey() return the leaf hashes.
+type HashAddressedNode struct {
+	HashableNode
+}
+
+// Node returns the stored HashableNode.
+func (hn HashAddressedNode) Node() HashableNode {
+	return hn.HashableNode
+}
+
+// Address returns the hash of node, which is the key in HashNodePool.
+func (hn HashAddressedNode) Address() []byte {
+	return hn.Hash()
+}
+
+// LeftKey returns the hash of left leaf.
+func (hn HashAddressedNode) LeftKey() []byte {
+	return hn.LeftHash()
+}
+
+// RightKey returns the hash of right leaf.
+func (hn HashAddressedNode) RightKey() []byte {
+	return hn.RightHash()
+}
+
+// HashNodePool is the content-addressed NodePool; HashableNode is stored by
+// it's hash, so the same subtree in the different versions of tree is stored
+// only once. Tree can be loaded from HashNodePool by root hash,
+//
+//	tr, err := avl.NewTree(rootHash, hashNodePool)
+//
+// HashNodePool keeps the snapshot of node, not the node itself, so the stored
+// versions are not changed when the MutableNode is changed by TreeGenerator
+// later. The snapshot is ProofNode, so the user's value is not stored; keep the
+// value by ValueHash(). BaseNode and ProofNode are already immutable, so they
+// are stored as they are.
+type HashNodePool struct {
+	m *sync.Map
+}
+
+func NewHashNodePool(m *sync.Map) *HashNodePool {
+	if m == nil {
+		m = &sync.Map{}
+	}
+
+	return &HashNodePool{m: m}
+}
+
+// Get returns node by hash.
+func (hp *HashNodePool) Get(hash []byte) (avl.Node, error) {
+	v, found := hp.m.Load(string(hash))
+	if !found {
+		return nil, nil
+	}
+
+	node, ok := v.(HashableNode)
+	if !ok {
+		return nil, NotHashableNodeError.Wrapf("type=%T", v)
+	}
+
+	return HashAddressedNode{HashableNode: node}, nil
+}
+
+// Set stores HashableNode by it's hash. If the node of same hash already
+// exists, Set does nothing.
+func (hp *HashNodePool) Set(node avl.Node) error {
+	var hn HashableNode
+	switch t := node.(type) {
+	case HashAddressedNode:
+		hn = t.HashableNode
+	case HashableNode:
+		hn = t
+	default:
+		return NotHashableNodeError.Wrapf("key=%x type=%T", node.Key(), node)
+	}
+
+	if hn.Hash() == nil {
+		return xerrors.Errorf("empty hash; key=%x", hn.Key())
+	}
+
+	_, _ = hp.m.LoadOrStore(string(hn.Hash()), snapshotNode(hn))
+
+	return nil
+}
+
+// snapshotNode returns the immutable copy of HashableNode.
+func snapshotNode(node HashableNode) HashableNode {
+	switch t := node.(type) {
+	case ProofNode, BaseNode:
+		return t
+	default:
+		return NewProofNode(node)
+	}
+}
+
+// SetTree stores all the nodes of hashable Tree and returns the root hash.
+func (hp *HashNodePool) SetTree(tr *avl.Tree) ([]byte, error) {
+	root, ok := tr.Root().(HashableNode)
+	if !ok {
+		return nil, NotHashableNodeError.Wrapf("root type=%T", tr.Root())
+	}
+
+	if err := tr.Traverse(func(node avl.Node) (bool, error) {
+		if err := hp.Set(node); err != nil {
+			return false, err
+		}
+
+		return true, nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return root.Hash(), nil
+}
+
+func (hp *HashNodePool) Traverse(f avl.NodeTraverseFunc) error {
+	var err error
+	hp.m.Range(func(_, value interface{}) bool {
+		var keep bool
+		node, ok := value.(HashableNode)
+		if !ok {
+			err = NotHashableNodeError.Wrapf("type=%T", value)
+			return false
+		}
+		if keep, err = f(HashAddressedNode{HashableNode: node}); err != nil {
+			return false
+		}
+
+		return keep
+	})
+
+	return err
+}
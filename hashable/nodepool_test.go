@@ -0,0 +1,204 @@
+package hashable
+
+import (
+	"testing"
+
+	"github.com/spikeekips/avl"
+	"github.com/stretchr/testify/suite"
+)
+
+type testHashNodePool struct {
+	suite.Suite
+	prover ExampleProver
+}
+
+func (t *testHashNodePool) newTree(n int, values map[int]int) *avl.Tree {
+	tg := avl.NewTreeGenerator()
+	for i := 0; i < n; i++ {
+		_, err := tg.Add(&ExampleHashableMutableNode{key: testKey(i), value: values[i]})
+		t.NoError(err)
+	}
+
+	tr, err := tg.Tree()
+	t.NoError(err)
+	t.NoError(SetTreeNodeHash(tr.Root().(HashableMutableNode), t.prover.GenerateNodeHash))
+
+	return tr
+}
+
+func (t *testHashNodePool) TestLoadByRootHash() {
+	hp := NewHashNodePool(nil)
+
+	tr := t.newTree(20, nil)
+	rootHash, err := hp.SetTree(tr)
+	t.NoError(err)
+
+	loaded, err := avl.NewTree(rootHash, hp)
+	t.NoError(err)
+	t.NoError(loaded.IsValid())
+
+	for i := 0; i < 20; i++ {
+		n, err := loaded.Get(testKey(i))
+		t.NoError(err)
+		t.NotNil(n)
+
+		an := n.(HashAddressedNode)
+		t.Equal(testKey(i), an.Key())
+		t.Equal(an.LeftHash(), an.LeftKey())
+		t.Equal(an.RightHash(), an.RightKey())
+
+		_, ok := an.Node().(ProofNode)
+		t.True(ok)
+	}
+}
+
+func (t *testHashNodePool) TestVersions() {
+	hp := NewHashNodePool(nil)
+
+	v0 := t.newTree(20, nil)
+	rootHash0, err := hp.SetTree(v0)
+	t.NoError(err)
+
+	var count0 int
+	_ = hp.Traverse(func(avl.Node) (bool, error) {
+		count0++
+		return true, nil
+	})
+	t.Equal(20, count0)
+
+	// NOTE only the value of 19 is changed
+	v1 := t.newTree(20, map[int]int{19: 33})
+	rootHash1, err := hp.SetTree(v1)
+	t.NoError(err)
+	t.NotEqual(rootHash0, rootHash1)
+
+	var count1 int
+	_ = hp.Traverse(func(avl.Node) (bool, error) {
+		count1++
+		return true, nil
+	})
+
+	_, parents, err := v1.GetWithParents(testKey(19))
+	t.NoError(err)
+
+	// NOTE only the changed node and it's parents are added.
+	t.Equal(count0+len(parents)+1, count1)
+
+	for _, c := range []struct {
+		rootHash []byte
+		value    int
+	}{
+		{rootHash: rootHash0, value: 0},
+		{rootHash: rootHash1, value: 33},
+	} {
+		tr, err := avl.NewTree(c.rootHash, hp)
+		t.NoError(err)
+
+		n, err := tr.Get(testKey(19))
+		t.NoError(err)
+		t.Equal(int64ToBytes(int64(c.value)), n.(HashableNode).ValueHash())
+	}
+}
+
+func (t *testHashNodePool) TestOrphans() {
+	hp := NewHashNodePool(nil)
+
+	v0 := t.newTree(20, nil)
+	rootHash0, err := hp.SetTree(v0)
+	t.NoError(err)
+
+	// NOTE only the value of 19 is changed
+	v1 := t.newTree(20, map[int]int{19: 33})
+	rootHash1, err := hp.SetTree(v1)
+	t.NoError(err)
+
+	// NOTE the nodes of version 0, which have the same keys with version 1,
+	// are not reachable from rootHash1.
+	node, parents, err := v0.GetWithParents(testKey(19))
+	t.NoError(err)
+
+	expected := [][]byte{node.(HashableNode).Hash()}
+	for _, p := range parents {
+		expected = append(expected, p.(HashableNode).Hash())
+	}
+
+	orphans, err := avl.NewGarbageCollector(hp).Orphans(rootHash1)
+	t.NoError(err)
+	t.ElementsMatch(expected, orphans)
+	t.Contains(orphans, rootHash0)
+
+	orphans, err = avl.NewGarbageCollector(hp).Orphans(rootHash0, rootHash1)
+	t.NoError(err)
+	t.Empty(orphans)
+}
+
+func (t *testHashNodePool) TestSameGenerator() {
+	hp := NewHashNodePool(nil)
+
+	tg := avl.NewTreeGenerator()
+	add := func(start, end int) []byte {
+		for i := start; i < end; i++ {
+			_, err := tg.Add(&ExampleHashableMutableNode{key: testKey(i)})
+			t.NoError(err)
+		}
+
+		for _, n := range tg.Nodes() {
+			n.(HashableMutableNode).ResetHash()
+		}
+
+		tr, err := tg.Tree()
+		t.NoError(err)
+		t.NoError(SetTreeNodeHash(tr.Root().(HashableMutableNode), t.prover.GenerateNodeHash))
+
+		rootHash, err := hp.SetTree(tr)
+		t.NoError(err)
+
+		return rootHash
+	}
+
+	rootHash0 := add(0, 10)
+
+	// NOTE the nodes of version 0 are changed by the same generator
+	rootHash1 := add(10, 20)
+	t.NotEqual(rootHash0, rootHash1)
+
+	for _, c := range []struct {
+		rootHash []byte
+		n        int
+	}{
+		{rootHash: rootHash0, n: 10},
+		{rootHash: rootHash1, n: 20},
+	} {
+		tr, err := avl.NewTree(c.rootHash, hp)
+		t.NoError(err)
+		t.Equal(c.rootHash, tr.Root().(HashableNode).Hash())
+
+		// NOTE the other version is in the same HashNodePool, so the orphans
+		// are not checked.
+		var count int
+		t.NoError(tr.Traverse(func(node avl.Node) (bool, error) {
+			h, err := t.prover.GenerateNodeHash(node.(HashableNode))
+			t.NoError(err)
+			t.Equal(node.(HashableNode).Hash(), h)
+			count++
+
+			return true, nil
+		}))
+		t.Equal(c.n, count)
+
+		for i := 0; i < 20; i++ {
+			n, err := tr.Get(testKey(i))
+			t.NoError(err)
+
+			if i < c.n {
+				t.NotNil(n, "key=%s", testKey(i))
+			} else {
+				t.Nil(n, "key=%s", testKey(i))
+			}
+		}
+	}
+}
+
+func TestHashNodePool(t *testing.T) {
+	suite.Run(t, new(testHashNodePool))
+}
@@ -0,0 +1,222 @@
+package hashable
+
+import (
+	"bytes"
+	"container/list"
+	"sync"
+
+	"github.com/spikeekips/avl"
+)
+
+var (
+	TamperedNodeError = avl.NewWrapError("tampered node")
+)
+
+// DefaultVerifiedNodePoolCapacity is the default number of the committed
+// hashes, which VerifiedNodePool keeps.
+const DefaultVerifiedNodePoolCapacity = 1024
+
+type verifiedHash struct {
+	key  string
+	hash []byte
+}
+
+// VerifiedNodePool checks every node from the untrusted NodePool. It starts
+// from the trusted root hash; the node from NodePool.Get() must be hashed to
+// the hash, which it's parent committed to LeftHash() or RightHash(). If not,
+// Get() returns TamperedNodeError.
+//
+// The committed hashes of the leaves of verified nodes are kept with LRU up to
+// the capacity, so the nodes fetched from root like Tree.Get() are verified by
+// it's parent. If the committed hash of key is not kept, the path from root to
+// key is verified again.
+type VerifiedNodePool struct {
+	sync.Mutex
+	np       avl.NodePool
+	hashFunc NodeHashFunc
+	rootKey  []byte
+	rootHash []byte
+	capacity int
+	ll       *list.List
+	hashes   map[string]*list.Element
+}
+
+func NewVerifiedNodePool(np avl.NodePool, rootKey, rootHash []byte, hashFunc NodeHashFunc) *VerifiedNodePool {
+	return NewVerifiedNodePoolWithCapacity(np, rootKey, rootHash, hashFunc, DefaultVerifiedNodePoolCapacity)
+}
+
+// NewVerifiedNodePoolWithCapacity returns new VerifiedNodePool, which keeps
+// the committed hashes up to capacity.
+func NewVerifiedNodePoolWithCapacity(
+	np avl.NodePool, rootKey, rootHash []byte, hashFunc NodeHashFunc, capacity int,
+) *VerifiedNodePool {
+	return &VerifiedNodePool{
+		np:       np,
+		hashFunc: hashFunc,
+		rootKey:  rootKey,
+		rootHash: rootHash,
+		capacity: capacity,
+		ll:       list.New(),
+		hashes:   map[string]*list.Element{},
+	}
+}
+
+// NewVerifiedTree loads Tree with VerifiedNodePool. All the nodes of Tree are
+// verified from the trusted root hash.
+func NewVerifiedTree(rootKey, rootHash []byte, np avl.NodePool, hashFunc NodeHashFunc) (*avl.Tree, error) {
+	return avl.NewTree(rootKey, NewVerifiedNodePool(np, rootKey, rootHash, hashFunc))
+}
+
+// NodePool returns the inner NodePool.
+func (vp *VerifiedNodePool) NodePool() avl.NodePool {
+	return vp.np
+}
+
+func (vp *VerifiedNodePool) Get(key []byte) (avl.Node, error) {
+	if key == nil {
+		return nil, nil
+	}
+
+	if expected, found := vp.committed(key); found {
+		return vp.get(key, expected)
+	}
+
+	return vp.getFromRoot(key)
+}
+
+// getFromRoot verifies the nodes from root to key.
+func (vp *VerifiedNodePool) getFromRoot(key []byte) (avl.Node, error) {
+	current, expected := vp.rootKey, vp.rootHash
+	for {
+		node, err := vp.get(current, expected)
+		if err != nil {
+			return nil, err
+		}
+
+		hn := node.(HashableNode)
+
+		c := avl.CompareKey(key, current)
+		switch {
+		case c == 0:
+			return node, nil
+		case c < 0:
+			current, expected = hn.LeftKey(), hn.LeftHash()
+		default:
+			current, expected = hn.RightKey(), hn.RightHash()
+		}
+
+		if current == nil {
+			return nil, TamperedNodeError.Wrapf("node is not committed by verified parent: key=%x", key)
+		}
+	}
+}
+
+func (vp *VerifiedNodePool) get(key, expected []byte) (avl.Node, error) {
+	node, err := vp.np.Get(key)
+	if err != nil {
+		return nil, err
+	} else if node == nil {
+		return nil, TamperedNodeError.Wrapf("committed node is missing: key=%x", key)
+	}
+
+	hn, ok := node.(HashableNode)
+	if !ok {
+		return nil, NotHashableNodeError.Wrapf("key=%x type=%T", key, node)
+	}
+
+	if err := vp.verify(key, expected, hn); err != nil {
+		return nil, err
+	}
+
+	vp.commit(hn.LeftKey(), hn.LeftHash())
+	vp.commit(hn.RightKey(), hn.RightHash())
+
+	return node, nil
+}
+
+// committed returns the committed hash of key.
+func (vp *VerifiedNodePool) committed(key []byte) ([]byte, bool) {
+	if avl.EqualKey(key, vp.rootKey) {
+		return vp.rootHash, true
+	}
+
+	vp.Lock()
+	defer vp.Unlock()
+
+	e, found := vp.hashes[string(key)]
+	if !found {
+		return nil, false
+	}
+
+	vp.ll.MoveToFront(e)
+
+	return e.Value.(verifiedHash).hash, true
+}
+
+// commit keeps the committed hash of leaf. If the number of hashes is over the
+// capacity, the least recently used hash is removed.
+func (vp *VerifiedNodePool) commit(key, hash []byte) {
+	if key == nil || vp.capacity < 1 {
+		return
+	}
+
+	vp.Lock()
+	defer vp.Unlock()
+
+	if e, found := vp.hashes[string(key)]; found {
+		e.Value = verifiedHash{key: string(key), hash: hash}
+		vp.ll.MoveToFront(e)
+
+		return
+	}
+
+	vp.hashes[string(key)] = vp.ll.PushFront(verifiedHash{key: string(key), hash: hash})
+
+	for vp.ll.Len() > vp.capacity {
+		e := vp.ll.Back()
+		vp.ll.Remove(e)
+		delete(vp.hashes, e.Value.(verifiedHash).key)
+	}
+}
+
+func (vp *VerifiedNodePool) verify(key, expected []byte, node HashableNode) error {
+	if !avl.EqualKey(key, node.Key()) {
+		return TamperedNodeError.Wrapf("key not match: key=%x node=%x", key, node.Key())
+	}
+
+	if !bytes.Equal(node.Hash(), expected) {
+		return TamperedNodeError.Wrapf(
+			"hash not match with parent: key=%x hash=%x != committed=%x",
+			key, node.Hash(), expected,
+		)
+	}
+
+	h, err := vp.hashFunc(node)
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(h, expected) {
+		return TamperedNodeError.Wrapf(
+			"generated hash not match with parent: key=%x generated=%x != committed=%x",
+			key, h, expected,
+		)
+	}
+
+	if (node.LeftKey() == nil) != (node.LeftHash() == nil) ||
+		(node.RightKey() == nil) != (node.RightHash() == nil) {
+		return TamperedNodeError.Wrapf("leaf key and leaf hash not match: key=%x", key)
+	}
+
+	return nil
+}
+
+// Set stores node to the inner NodePool. The node is not verified.
+func (vp *VerifiedNodePool) Set(node avl.Node) error {
+	return vp.np.Set(node)
+}
+
+// Traverse traverses the inner NodePool. The nodes are not verified.
+func (vp *VerifiedNodePool) Traverse(f avl.NodeTraverseFunc) error {
+	return vp.np.Traverse(f)
+}
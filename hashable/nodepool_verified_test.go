@@ -0,0 +1,144 @@
+package hashable
+
+import (
+	"testing"
+
+	"github.com/spikeekips/avl"
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/xerrors"
+)
+
+type testVerifiedNodePool struct {
+	suite.Suite
+	prover   ExampleProver
+	np       *avl.MapNodePool
+	rootKey  []byte
+	rootHash []byte
+}
+
+func (t *testVerifiedNodePool) SetupTest() {
+	keys := make([]int, 30)
+	for i := range keys {
+		keys[i] = i
+	}
+
+	tr, err := newTestHashedTree(keys, t.prover.GenerateNodeHash)
+	t.NoError(err)
+
+	t.np = avl.NewMapNodePool(nil)
+	_ = tr.Traverse(func(node avl.Node) (bool, error) {
+		_ = t.np.Set(node)
+		return true, nil
+	})
+
+	t.rootKey = tr.Root().Key()
+	t.rootHash = tr.Root().(HashableNode).Hash()
+}
+
+func (t *testVerifiedNodePool) tamper(key []byte, resetHash bool) {
+	n, _ := t.np.Get(key)
+	orig := n.(*ExampleHashableMutableNode)
+
+	tampered := *orig
+	tampered.value = 33
+	if resetHash {
+		h, err := t.prover.GenerateNodeHash(&tampered)
+		t.NoError(err)
+		tampered.hash = h
+	}
+
+	_ = t.np.Set(&tampered)
+}
+
+func (t *testVerifiedNodePool) TestGet() {
+	tr, err := NewVerifiedTree(t.rootKey, t.rootHash, t.np, t.prover.GenerateNodeHash)
+	t.NoError(err)
+
+	for i := 0; i < 30; i++ {
+		n, err := tr.Get(testKey(i))
+		t.NoError(err)
+		t.NotNil(n)
+	}
+
+	n, err := tr.Get(testKey(99))
+	t.NoError(err)
+	t.Nil(n)
+
+	t.NoError(tr.IsValid())
+}
+
+func (t *testVerifiedNodePool) TestWrongRootHash() {
+	_, err := NewVerifiedTree(t.rootKey, []byte("showme"), t.np, t.prover.GenerateNodeHash)
+	t.True(xerrors.Is(err, TamperedNodeError))
+}
+
+func (t *testVerifiedNodePool) TestTamperedValue() {
+	t.tamper(testKey(5), false)
+
+	tr, err := NewVerifiedTree(t.rootKey, t.rootHash, t.np, t.prover.GenerateNodeHash)
+	t.NoError(err)
+
+	_, err = tr.Get(testKey(5))
+	t.True(xerrors.Is(err, TamperedNodeError))
+}
+
+func (t *testVerifiedNodePool) TestTamperedHash() {
+	t.tamper(testKey(5), true)
+
+	tr, err := NewVerifiedTree(t.rootKey, t.rootHash, t.np, t.prover.GenerateNodeHash)
+	t.NoError(err)
+
+	_, err = tr.Get(testKey(5))
+	t.True(xerrors.Is(err, TamperedNodeError))
+}
+
+func (t *testVerifiedNodePool) TestNotCommitted() {
+	vp := NewVerifiedNodePool(t.np, t.rootKey, t.rootHash, t.prover.GenerateNodeHash)
+
+	// NOTE the node, which is not under root, is not committed
+	t.NoError(t.np.Set(&ExampleHashableMutableNode{key: testKey(99), hash: []byte("showme")}))
+
+	_, err := vp.Get(testKey(99))
+	t.True(xerrors.Is(err, TamperedNodeError))
+}
+
+func (t *testVerifiedNodePool) TestGetWithoutParent() {
+	vp := NewVerifiedNodePool(t.np, t.rootKey, t.rootHash, t.prover.GenerateNodeHash)
+
+	// NOTE the parents of 5 are not fetched, so the path from root is verified.
+	n, err := vp.Get(testKey(5))
+	t.NoError(err)
+	t.Equal(testKey(5), n.Key())
+
+	t.tamper(testKey(7), true)
+
+	_, err = NewVerifiedNodePool(t.np, t.rootKey, t.rootHash, t.prover.GenerateNodeHash).Get(testKey(7))
+	t.True(xerrors.Is(err, TamperedNodeError))
+}
+
+func (t *testVerifiedNodePool) TestCapacity() {
+	vp := NewVerifiedNodePoolWithCapacity(t.np, t.rootKey, t.rootHash, t.prover.GenerateNodeHash, 3)
+
+	tr, err := avl.NewTree(t.rootKey, vp)
+	t.NoError(err)
+
+	for i := 0; i < 30; i++ {
+		n, err := tr.Get(testKey(i))
+		t.NoError(err)
+		t.NotNil(n)
+
+		t.True(len(vp.hashes) <= 3)
+		t.Equal(len(vp.hashes), vp.ll.Len())
+	}
+
+	t.NoError(tr.IsValid())
+
+	t.tamper(testKey(5), true)
+
+	_, err = tr.Get(testKey(5))
+	t.True(xerrors.Is(err, TamperedNodeError))
+}
+
+func TestVerifiedNodePool(t *testing.T) {
+	suite.Run(t, new(testVerifiedNodePool))
+}
@@ -0,0 +1,294 @@
+package hashable
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/spikeekips/avl"
+)
+
+// ProofNode is the snapshot of HashableNode, which is used in the proofs.
+// ProofNode also implements HashableNode, so it's hash can be generated again
+// by NodeHashFunc.
+type ProofNode struct {
+	key       []byte
+	height    int16
+	leftKey   []byte
+	rightKey  []byte
+	hash      []byte
+	leftHash  []byte
+	rightHash []byte
+	valueHash []byte
+}
+
+// NewProofNode makes ProofNode from HashableNode.
+func NewProofNode(node HashableNode) ProofNode {
+	return ProofNode{
+		key:       node.Key(),
+		height:    node.Height(),
+		leftKey:   node.LeftKey(),
+		rightKey:  node.RightKey(),
+		hash:      node.Hash(),
+		leftHash:  node.LeftHash(),
+		rightHash: node.RightHash(),
+		valueHash: node.ValueHash(),
+	}
+}
+
+func (pn ProofNode) Key() []byte {
+	return pn.key
+}
+
+func (pn ProofNode) Height() int16 {
+	return pn.height
+}
+
+func (pn ProofNode) LeftKey() []byte {
+	return pn.leftKey
+}
+
+func (pn ProofNode) RightKey() []byte {
+	return pn.rightKey
+}
+
+func (pn ProofNode) Hash() []byte {
+	return pn.hash
+}
+
+func (pn ProofNode) LeftHash() []byte {
+	return pn.leftHash
+}
+
+func (pn ProofNode) RightHash() []byte {
+	return pn.rightHash
+}
+
+func (pn ProofNode) ValueHash() []byte {
+	return pn.valueHash
+}
+
+// leafHash returns the hash of left or right leaf.
+func (pn ProofNode) leafHash(isLeft bool) []byte {
+	if isLeft {
+		return pn.leftHash
+	}
+
+	return pn.rightHash
+}
+
+// prove generates the hash of node again and compares it with the stored
+// hash.
+func (pn ProofNode) prove(hashFunc NodeHashFunc) error {
+	h, err := hashFunc(pn)
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(pn.hash, h) {
+		return InvalidProofError.Wrapf(
+			"node hash not match: key=%x proof.hash=%x != generated=%x",
+			pn.key,
+			pn.hash,
+			h,
+		)
+	}
+
+	return nil
+}
+
+func (pn ProofNode) MarshalBinary() ([]byte, error) {
+	return json.Marshal(pn)
+}
+
+func (pn ProofNode) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"key":        pn.key,
+		"height":     pn.height,
+		"left_key":   pn.leftKey,
+		"right_key":  pn.rightKey,
+		"hash":       pn.hash,
+		"left_hash":  pn.leftHash,
+		"right_hash": pn.rightHash,
+		"value_hash": pn.valueHash,
+	})
+}
+
+// ProofStep is the parent node in PathProof. ProofStep records which side of
+// the parent the child was taken.
+type ProofStep struct {
+	node   ProofNode
+	isLeft bool
+}
+
+// Node returns the parent node.
+func (ps ProofStep) Node() ProofNode {
+	return ps.node
+}
+
+// IsLeft returns true if the child is the left leaf of the parent.
+func (ps ProofStep) IsLeft() bool {
+	return ps.isLeft
+}
+
+func (ps ProofStep) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"node":    ps.node,
+		"is_left": ps.isLeft,
+	})
+}
+
+// PathProof proves the node with the path from the node to the root. Each
+// parent in the path records the direction of it's child, so the verifier can
+// check the key ordering in every level.
+type PathProof struct {
+	node  ProofNode
+	steps []ProofStep // NOTE the nearest parent is the first item
+}
+
+// NewPathProof makes PathProof. The parents should be ordered like the result
+// of Tree.GetWithParents(); the root is the first item.
+func NewPathProof(node HashableNode, parents []HashableNode) (PathProof, error) {
+	steps, err := newProofSteps(node, parents)
+	if err != nil {
+		return PathProof{}, err
+	}
+
+	return PathProof{node: NewProofNode(node), steps: steps}, nil
+}
+
+// newProofSteps checks the parents are contiguous from the root to the node
+// and records the direction of each parent.
+func newProofSteps(node avl.Node, parents []HashableNode) ([]ProofStep, error) {
+	steps := make([]ProofStep, len(parents))
+
+	child := node
+	for i := len(parents) - 1; i >= 0; i-- {
+		p := parents[i]
+
+		isLeft := avl.CompareKey(child.Key(), p.Key()) < 0
+
+		var leafKey []byte
+		if isLeft {
+			leafKey = p.LeftKey()
+		} else {
+			leafKey = p.RightKey()
+		}
+
+		if !avl.EqualKey(leafKey, child.Key()) {
+			return nil, InvalidProofError.Wrapf(
+				"parents are not contiguous: parent=%x child=%x isLeft=%v",
+				p.Key(), child.Key(), isLeft,
+			)
+		}
+
+		steps[len(parents)-1-i] = ProofStep{node: NewProofNode(p), isLeft: isLeft}
+		child = p
+	}
+
+	return steps, nil
+}
+
+// Node returns the proven node.
+func (pr PathProof) Node() ProofNode {
+	return pr.node
+}
+
+// Steps returns the parents of node. The nearest parent is the first item.
+func (pr PathProof) Steps() []ProofStep {
+	return pr.steps
+}
+
+// RootHash returns the hash of the top node in proof.
+func (pr PathProof) RootHash() []byte {
+	if len(pr.steps) < 1 {
+		return pr.node.hash
+	}
+
+	return pr.steps[len(pr.steps)-1].node.hash
+}
+
+// Prove checks the proof with the given root hash. Prove checks,
+//
+// - the hash of every node in proof is correctly generated by hashFunc.
+// - the hash of child is same with the leaf hash of parent on the recorded
+// side.
+// - the key of proven node and child are bounded by the key of parent on the
+// recorded side.
+// - the height of parent is greater than child.
+// - the hash of top node is same with root hash.
+//
+// The direction of each parent is only sound when hashFunc binds each leaf
+// hash to it's side; see NodeHashFunc.
+func (pr PathProof) Prove(rootHash []byte, hashFunc NodeHashFunc) error {
+	if err := pr.node.prove(hashFunc); err != nil {
+		return err
+	}
+
+	if err := proveProofSteps(pr.node.key, pr.node, pr.steps, hashFunc); err != nil {
+		return err
+	}
+
+	if !bytes.Equal(pr.RootHash(), rootHash) {
+		return InvalidProofError.Wrapf(
+			"top node hash not match with root hash: topnode.hash=%x != root.hash=%x",
+			pr.RootHash(),
+			rootHash,
+		)
+	}
+
+	return nil
+}
+
+func proveProofSteps(key []byte, node ProofNode, steps []ProofStep, hashFunc NodeHashFunc) error {
+	child := node
+	for _, s := range steps {
+		p := s.node
+		if err := p.prove(hashFunc); err != nil {
+			return err
+		}
+
+		if !bytes.Equal(child.hash, p.leafHash(s.isLeft)) {
+			return InvalidProofError.Wrapf(
+				"child hash not match with leaf hash of parent: child=%x parent=%x isLeft=%v",
+				child.key, p.key, s.isLeft,
+			)
+		}
+
+		if p.height <= child.height {
+			return InvalidProofError.Wrapf(
+				"parent height must be greater than child: child=%x(%d) parent=%x(%d)",
+				child.key, child.height, p.key, p.height,
+			)
+		}
+
+		for _, k := range [][]byte{key, child.key} {
+			if c := avl.CompareKey(k, p.key); (s.isLeft && c >= 0) || (!s.isLeft && c <= 0) {
+				return InvalidProofError.Wrapf(
+					"key is not bounded by parent: key=%x parent=%x isLeft=%v",
+					k, p.key, s.isLeft,
+				)
+			}
+		}
+
+		child = p
+	}
+
+	return nil
+}
+
+func (pr PathProof) String() string {
+	b, _ := json.Marshal(pr)
+
+	return string(b)
+}
+
+func (pr PathProof) MarshalBinary() ([]byte, error) {
+	return json.Marshal(pr)
+}
+
+func (pr PathProof) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"node":  pr.node,
+		"steps": pr.steps,
+	})
+}
@@ -0,0 +1,144 @@
+package hashable
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/spikeekips/avl"
+)
+
+// AbsenceProver generates and proves the proof of absence of key.
+type AbsenceProver interface {
+	Prover
+	// ProveAbsence generates the proof, which the key does not exist in tree.
+	// path is the visited nodes from root, like the result of Tree.Lookup().
+	ProveAbsence(key []byte, path []HashableNode) (Proof, error)
+	// VerifyAbsence checks the proof of absence with key and root hash.
+	VerifyAbsence(proof Proof, key, rootHash []byte) error
+}
+
+// AbsenceProof proves the key is not in tree. AbsenceProof has the path from
+// root to the node, where the key would be inserted; the last node does not
+// have the leaf on the side of key.
+type AbsenceProof struct {
+	key   []byte
+	node  ProofNode
+	steps []ProofStep // NOTE the nearest parent is the first item
+}
+
+// NewAbsenceProof makes AbsenceProof. The path should be ordered like the
+// result of Tree.Lookup(); the root is the first item and the last item is the
+// node, where key would be inserted.
+func NewAbsenceProof(key []byte, path []HashableNode) (AbsenceProof, error) {
+	if len(path) < 1 {
+		return AbsenceProof{}, InvalidProofError.Wrapf("empty path")
+	}
+
+	last := path[len(path)-1]
+
+	c := avl.CompareKey(key, last.Key())
+	if c == 0 {
+		return AbsenceProof{}, InvalidProofError.Wrapf("key exists: key=%x", key)
+	}
+
+	var leafKey []byte
+	if c < 0 {
+		leafKey = last.LeftKey()
+	} else {
+		leafKey = last.RightKey()
+	}
+	if leafKey != nil {
+		return AbsenceProof{}, InvalidProofError.Wrapf(
+			"last node of path has leaf on the side of key: key=%x last=%x leaf=%x",
+			key, last.Key(), leafKey,
+		)
+	}
+
+	steps, err := newProofSteps(last, path[:len(path)-1])
+	if err != nil {
+		return AbsenceProof{}, err
+	}
+
+	return AbsenceProof{key: key, node: NewProofNode(last), steps: steps}, nil
+}
+
+// Key returns the absent key.
+func (pr AbsenceProof) Key() []byte {
+	return pr.key
+}
+
+// Node returns the last node of path.
+func (pr AbsenceProof) Node() ProofNode {
+	return pr.node
+}
+
+// Steps returns the parents of the last node. The nearest parent is the first
+// item.
+func (pr AbsenceProof) Steps() []ProofStep {
+	return pr.steps
+}
+
+// RootHash returns the hash of the top node in proof.
+func (pr AbsenceProof) RootHash() []byte {
+	if len(pr.steps) < 1 {
+		return pr.node.hash
+	}
+
+	return pr.steps[len(pr.steps)-1].node.hash
+}
+
+// Prove checks the proof with the given key and root hash. Along with the
+// checks of PathProof.Prove(), the last node must not have the leaf hash on the
+// side of key. It's only sound when hashFunc binds each leaf hash to it's
+// side; see NodeHashFunc.
+func (pr AbsenceProof) Prove(key, rootHash []byte, hashFunc NodeHashFunc) error {
+	if !avl.EqualKey(pr.key, key) {
+		return InvalidProofError.Wrapf("key not match: proof.key=%x != key=%x", pr.key, key)
+	}
+
+	if err := pr.node.prove(hashFunc); err != nil {
+		return err
+	}
+
+	c := avl.CompareKey(key, pr.node.key)
+	if c == 0 {
+		return InvalidProofError.Wrapf("key exists: key=%x", key)
+	} else if pr.node.leafHash(c < 0) != nil {
+		return InvalidProofError.Wrapf(
+			"last node has leaf on the side of key: key=%x last=%x",
+			key, pr.node.key,
+		)
+	}
+
+	if err := proveProofSteps(key, pr.node, pr.steps, hashFunc); err != nil {
+		return err
+	}
+
+	if !bytes.Equal(pr.RootHash(), rootHash) {
+		return InvalidProofError.Wrapf(
+			"top node hash not match with root hash: topnode.hash=%x != root.hash=%x",
+			pr.RootHash(),
+			rootHash,
+		)
+	}
+
+	return nil
+}
+
+func (pr AbsenceProof) String() string {
+	b, _ := json.Marshal(pr)
+
+	return string(b)
+}
+
+func (pr AbsenceProof) MarshalBinary() ([]byte, error) {
+	return json.Marshal(pr)
+}
+
+func (pr AbsenceProof) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"key":   pr.key,
+		"node":  pr.node,
+		"steps": pr.steps,
+	})
+}
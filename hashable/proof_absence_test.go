@@ -0,0 +1,113 @@
+package hashable
+
+import (
+	"testing"
+
+	"github.com/spikeekips/avl"
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/xerrors"
+)
+
+type testAbsenceProof struct {
+	suite.Suite
+	prover ExampleProver
+	tr     *avl.Tree
+}
+
+func (t *testAbsenceProof) SetupTest() {
+	var keys []int
+	for i := 10; i < 50; i += 2 {
+		keys = append(keys, i)
+	}
+
+	tr, err := newTestHashedTree(keys, t.prover.GenerateNodeHash)
+	t.NoError(err)
+
+	t.tr = tr
+}
+
+func (t *testAbsenceProof) rootHash() []byte {
+	return t.tr.Root().(HashableNode).Hash()
+}
+
+func (t *testAbsenceProof) proof(key []byte) (Proof, error) {
+	node, path, err := t.tr.Lookup(key)
+	t.NoError(err)
+	t.Nil(node)
+
+	return t.prover.ProveAbsence(key, toHashableNodes(path))
+}
+
+func (t *testAbsenceProof) TestProve() {
+	var _ AbsenceProver = t.prover
+
+	for i := 1; i < 60; i += 2 {
+		key := testKey(i)
+
+		pr, err := t.proof(key)
+		t.NoError(err, "key=%s", key)
+		t.NoError(t.prover.VerifyAbsence(pr, key, t.rootHash()), "key=%s", key)
+	}
+}
+
+func (t *testAbsenceProof) TestExistingKey() {
+	key := testKey(20)
+
+	node, parents, err := t.tr.Lookup(key)
+	t.NoError(err)
+	t.NotNil(node)
+
+	path := append(toHashableNodes(parents), node.(HashableNode))
+	_, err = t.prover.ProveAbsence(key, path)
+	t.True(xerrors.Is(err, InvalidProofError))
+}
+
+func (t *testAbsenceProof) TestOtherKey() {
+	pr, err := t.proof(testKey(21))
+	t.NoError(err)
+
+	err = t.prover.VerifyAbsence(pr, testKey(23), t.rootHash())
+	t.True(xerrors.Is(err, InvalidProofError))
+}
+
+func (t *testAbsenceProof) TestForgedKeyInProof() {
+	// NOTE the path of 21 is used to prove the existing key, 20.
+	pr, err := t.proof(testKey(21))
+	t.NoError(err)
+
+	ap := pr.(AbsenceProof)
+	ap.key = testKey(20)
+
+	err = t.prover.VerifyAbsence(ap, testKey(20), t.rootHash())
+	t.True(xerrors.Is(err, InvalidProofError))
+}
+
+func (t *testAbsenceProof) TestSwappedLeafHash() {
+	// NOTE 010 is the left leaf of 020; the forged proof moves the left hash
+	// to the right hash, so 020 looks like it has no left leaf.
+	root := &ExampleHashableMutableNode{key: testKey(20), height: 1}
+	t.NoError(root.SetLeft(&ExampleHashableMutableNode{key: testKey(10)}))
+	t.NoError(SetTreeNodeHash(root, t.prover.GenerateNodeHash))
+
+	forged := NewProofNode(root)
+	forged.rightKey, forged.leftKey = forged.leftKey, nil
+	forged.rightHash, forged.leftHash = forged.leftHash, nil
+
+	pr := AbsenceProof{key: testKey(10), node: forged}
+
+	err := t.prover.VerifyAbsence(pr, testKey(10), root.Hash())
+	t.True(xerrors.Is(err, InvalidProofError))
+	t.Contains(err.Error(), "node hash not match")
+}
+
+func (t *testAbsenceProof) TestWrongRootHash() {
+	pr, err := t.proof(testKey(21))
+	t.NoError(err)
+
+	err = t.prover.VerifyAbsence(pr, testKey(21), []byte("showme"))
+	t.True(xerrors.Is(err, InvalidProofError))
+}
+
+func TestAbsenceProof(t *testing.T) {
+	suite.Run(t, new(testAbsenceProof))
+}
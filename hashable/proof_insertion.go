@@ -0,0 +1,322 @@
+package hashable
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/spikeekips/avl"
+	"golang.org/x/xerrors"
+)
+
+// InsertionProof proves the state transition of hashable tree by inserting one
+// node. InsertionProof has the partial tree before insertion, which reveals the
+// path to the inserted node and the leaves of path. The leaves of path are
+// enough to replay the rotations of TreeGenerator.
+type InsertionProof struct {
+	node ProofNode
+	tree *ProofTree
+}
+
+// NewInsertionProof makes InsertionProof from hashable Tree. NewInsertionProof
+// should be called before the node is added to TreeGenerator. Only the key and
+// value hash of node are used.
+func NewInsertionProof(tr *avl.Tree, node HashableNode) (InsertionProof, error) {
+	found, parents, err := tr.Lookup(node.Key())
+	if err != nil {
+		return InsertionProof{}, err
+	}
+
+	paths := map[string]struct{}{}
+	for _, p := range parents {
+		paths[string(p.Key())] = struct{}{}
+	}
+	if found != nil {
+		paths[string(found.Key())] = struct{}{}
+	}
+
+	pt, err := newProofTree(tr, func(n avl.Node, _ bool) bool {
+		_, isPath := paths[string(n.Key())]
+
+		return isPath
+	})
+	if err != nil {
+		return InsertionProof{}, err
+	}
+
+	return InsertionProof{node: NewProofNode(node), tree: pt}, nil
+}
+
+// Node returns the inserted node. Only the key and value hash are meaningful.
+func (pr InsertionProof) Node() ProofNode {
+	return pr.node
+}
+
+// Tree returns the partial tree before insertion.
+func (pr InsertionProof) Tree() *ProofTree {
+	return pr.tree
+}
+
+// Prove checks the partial tree with the old root hash, and then replays the
+// insertion with TreeGenerator on the partial tree. The new root hash from the
+// replayed tree should be same with the given new root hash.
+func (pr InsertionProof) Prove(oldRootHash, newRootHash []byte, hashFunc NodeHashFunc) error {
+	if err := pr.tree.proveRoot(oldRootHash, hashFunc, func(ProofNode, bool) error {
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	// NOTE every node in the path of key should reveal it's leaves.
+	key := pr.node.key
+	for pt := pr.tree; pt != nil; {
+		for _, isLeft := range []bool{true, false} {
+			if pt.node.leafHash(isLeft) != nil && pt.leaf(isLeft) == nil {
+				return InvalidProofError.Wrapf(
+					"leaf of path is not revealed: key=%x isLeft=%v", pt.node.key, isLeft,
+				)
+			}
+		}
+
+		c := avl.CompareKey(key, pt.node.key)
+		if c == 0 {
+			break
+		}
+
+		pt = pt.leaf(c < 0)
+	}
+
+	root, replayed := newReplayNodes(pr.tree)
+
+	tg := avl.NewTreeGeneratorWithRoot(root)
+
+	inserted := &replayNode{key: pr.node.key, valueHash: pr.node.valueHash}
+	if _, err := tg.Add(inserted); err != nil {
+		return err
+	}
+
+	for _, n := range append(replayed, inserted) {
+		n.ResetHash()
+	}
+
+	newRoot, ok := tg.Root().(HashableMutableNode)
+	if !ok {
+		return xerrors.Errorf("not HashableMutableNode; %T", tg.Root())
+	}
+
+	if err := SetTreeNodeHash(newRoot, hashFunc); err != nil {
+		return err
+	}
+
+	if !bytes.Equal(newRoot.Hash(), newRootHash) {
+		return InvalidProofError.Wrapf(
+			"replayed root hash not match with new root hash: replayed=%x != new=%x",
+			newRoot.Hash(),
+			newRootHash,
+		)
+	}
+
+	return nil
+}
+
+func (pr InsertionProof) String() string {
+	b, _ := json.Marshal(pr)
+
+	return string(b)
+}
+
+func (pr InsertionProof) MarshalBinary() ([]byte, error) {
+	return json.Marshal(pr)
+}
+
+func (pr InsertionProof) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"node": pr.node,
+		"tree": pr.tree,
+	})
+}
+
+// newReplayNodes converts ProofTree to replayNode. The leaf, which is not
+// revealed, becomes the stub, which has only key and hash. It returns the root
+// and the replayNodes, which are not stub.
+func newReplayNodes(pt *ProofTree) (*replayNode, []*replayNode) {
+	rn := &replayNode{
+		key:       pt.node.key,
+		height:    pt.node.height,
+		valueHash: pt.node.valueHash,
+		hash:      pt.node.hash,
+	}
+
+	replayed := []*replayNode{rn}
+	for _, isLeft := range []bool{true, false} {
+		var leaf *replayNode
+		if l := pt.leaf(isLeft); l != nil {
+			var rs []*replayNode
+			leaf, rs = newReplayNodes(l)
+			replayed = append(replayed, rs...)
+		} else if h := pt.node.leafHash(isLeft); h != nil {
+			var key []byte
+			if isLeft {
+				key = pt.node.leftKey
+			} else {
+				key = pt.node.rightKey
+			}
+
+			// NOTE the height of stub is unknown.
+			leaf = &replayNode{key: key, height: -1, hash: h, stub: true}
+		}
+
+		if leaf == nil {
+			continue
+		}
+
+		if isLeft {
+			rn.left = leaf
+		} else {
+			rn.right = leaf
+		}
+	}
+
+	return rn, replayed
+}
+
+// replayNode is the HashableMutableNode for replaying the insertion of
+// InsertionProof.
+type replayNode struct {
+	key       []byte
+	height    int16
+	left      *replayNode
+	right     *replayNode
+	valueHash []byte
+	hash      []byte
+	stub      bool
+}
+
+func (rn *replayNode) Key() []byte {
+	return rn.key
+}
+
+func (rn *replayNode) Height() int16 {
+	return rn.height
+}
+
+func (rn *replayNode) SetHeight(height int16) error {
+	if rn.stub {
+		return xerrors.Errorf("stub node can not be changed; key=%x", rn.key)
+	}
+
+	rn.height = height
+
+	return nil
+}
+
+func (rn *replayNode) Left() avl.MutableNode {
+	if rn.left == nil {
+		return nil
+	}
+
+	return rn.left
+}
+
+func (rn *replayNode) LeftKey() []byte {
+	if rn.left == nil {
+		return nil
+	}
+
+	return rn.left.key
+}
+
+func (rn *replayNode) SetLeft(node avl.MutableNode) error {
+	return rn.setLeaf(node, true)
+}
+
+func (rn *replayNode) Right() avl.MutableNode {
+	if rn.right == nil {
+		return nil
+	}
+
+	return rn.right
+}
+
+func (rn *replayNode) RightKey() []byte {
+	if rn.right == nil {
+		return nil
+	}
+
+	return rn.right.key
+}
+
+func (rn *replayNode) SetRight(node avl.MutableNode) error {
+	return rn.setLeaf(node, false)
+}
+
+func (rn *replayNode) setLeaf(node avl.MutableNode, isLeft bool) error {
+	if rn.stub {
+		return xerrors.Errorf("stub node can not be changed; key=%x", rn.key)
+	}
+
+	var leaf *replayNode
+	if node != nil {
+		r, ok := node.(*replayNode)
+		if !ok {
+			return xerrors.Errorf("not *replayNode; %T", node)
+		}
+		leaf = r
+	}
+
+	if isLeft {
+		rn.left = leaf
+	} else {
+		rn.right = leaf
+	}
+
+	return nil
+}
+
+func (rn *replayNode) Merge(node avl.MutableNode) error {
+	r, ok := node.(*replayNode)
+	if !ok {
+		return xerrors.Errorf("not *replayNode; %T", node)
+	}
+
+	rn.valueHash = r.valueHash
+
+	return nil
+}
+
+func (rn *replayNode) Hash() []byte {
+	return rn.hash
+}
+
+func (rn *replayNode) SetHash(h []byte) error {
+	rn.hash = h
+
+	return nil
+}
+
+func (rn *replayNode) ResetHash() {
+	if rn.stub {
+		return
+	}
+
+	rn.hash = nil
+}
+
+func (rn *replayNode) LeftHash() []byte {
+	if rn.left == nil {
+		return nil
+	}
+
+	return rn.left.hash
+}
+
+func (rn *replayNode) RightHash() []byte {
+	if rn.right == nil {
+		return nil
+	}
+
+	return rn.right.hash
+}
+
+func (rn *replayNode) ValueHash() []byte {
+	return rn.valueHash
+}
@@ -0,0 +1,118 @@
+package hashable
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/spikeekips/avl"
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/xerrors"
+)
+
+type testInsertionProof struct {
+	suite.Suite
+	prover ExampleProver
+}
+
+// insert adds node into TreeGenerator and returns the proof with old and new
+// root hash.
+func (t *testInsertionProof) insert(tg *avl.TreeGenerator, node *ExampleHashableMutableNode) (
+	InsertionProof, []byte, []byte,
+) {
+	tr, err := tg.Tree()
+	t.NoError(err)
+
+	oldRootHash := tr.Root().(HashableNode).Hash()
+
+	pr, err := NewInsertionProof(tr, node)
+	t.NoError(err)
+
+	parents, err := tg.Add(node)
+	t.NoError(err)
+
+	for _, p := range parents {
+		p.(HashableMutableNode).ResetHash()
+	}
+	if n, found := tg.Nodes()[string(node.Key())]; found {
+		n.(HashableMutableNode).ResetHash()
+	}
+
+	t.NoError(SetTreeNodeHash(tg.Root().(HashableMutableNode), t.prover.GenerateNodeHash))
+
+	return pr, oldRootHash, tg.Root().(HashableNode).Hash()
+}
+
+func (t *testInsertionProof) newGenerator() *avl.TreeGenerator {
+	tg := avl.NewTreeGenerator()
+	_, err := tg.Add(&ExampleHashableMutableNode{key: testKey(500)})
+	t.NoError(err)
+	t.NoError(SetTreeNodeHash(tg.Root().(HashableMutableNode), t.prover.GenerateNodeHash))
+
+	return tg
+}
+
+func (t *testInsertionProof) TestSequential() {
+	tg := t.newGenerator()
+
+	for i := 0; i < 100; i++ {
+		pr, oldRootHash, newRootHash := t.insert(tg, &ExampleHashableMutableNode{key: testKey(i)})
+		t.NoError(pr.Prove(oldRootHash, newRootHash, t.prover.GenerateNodeHash), "key=%d", i)
+	}
+}
+
+func (t *testInsertionProof) TestRandom() {
+	tg := t.newGenerator()
+
+	r := rand.New(rand.NewSource(33))
+	for _, i := range r.Perm(300) {
+		pr, oldRootHash, newRootHash := t.insert(tg, &ExampleHashableMutableNode{key: testKey(i + 1000)})
+		t.NoError(pr.Prove(oldRootHash, newRootHash, t.prover.GenerateNodeHash), "key=%d", i)
+	}
+}
+
+func (t *testInsertionProof) TestUpdate() {
+	tg := t.newGenerator()
+	for i := 0; i < 20; i++ {
+		_, _, _ = t.insert(tg, &ExampleHashableMutableNode{key: testKey(i)})
+	}
+
+	for _, i := range []int{0, 7, 500} {
+		pr, oldRootHash, newRootHash := t.insert(tg, &ExampleHashableMutableNode{key: testKey(i), value: 33})
+		t.NotEqual(oldRootHash, newRootHash)
+		t.NoError(pr.Prove(oldRootHash, newRootHash, t.prover.GenerateNodeHash), "key=%d", i)
+	}
+}
+
+func (t *testInsertionProof) TestWrongNewRootHash() {
+	tg := t.newGenerator()
+	for i := 0; i < 20; i++ {
+		_, _, _ = t.insert(tg, &ExampleHashableMutableNode{key: testKey(i)})
+	}
+
+	pr, oldRootHash, _ := t.insert(tg, &ExampleHashableMutableNode{key: testKey(30)})
+
+	err := pr.Prove(oldRootHash, []byte("showme"), t.prover.GenerateNodeHash)
+	t.True(xerrors.Is(err, InvalidProofError))
+
+	err = pr.Prove([]byte("showme"), oldRootHash, t.prover.GenerateNodeHash)
+	t.True(xerrors.Is(err, InvalidProofError))
+}
+
+func (t *testInsertionProof) TestPrunedPath() {
+	tg := t.newGenerator()
+	for i := 0; i < 20; i++ {
+		_, _, _ = t.insert(tg, &ExampleHashableMutableNode{key: testKey(i)})
+	}
+
+	pr, oldRootHash, newRootHash := t.insert(tg, &ExampleHashableMutableNode{key: testKey(30)})
+
+	// NOTE the leaf of root is pruned
+	pr.tree.left = nil
+
+	err := pr.Prove(oldRootHash, newRootHash, t.prover.GenerateNodeHash)
+	t.True(xerrors.Is(err, InvalidProofError))
+}
+
+func TestInsertionProof(t *testing.T) {
+	suite.Run(t, new(testInsertionProof))
+}
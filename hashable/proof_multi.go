@@ -0,0 +1,105 @@
+package hashable
+
+import (
+	"encoding/json"
+
+	"github.com/spikeekips/avl"
+)
+
+// MultiProof proves the multiple keys at once. The paths of keys are merged
+// into one partial tree, so the shared parents are stored only once.
+type MultiProof struct {
+	keys [][]byte
+	tree *ProofTree
+}
+
+// NewMultiProof makes MultiProof from hashable Tree. All the keys should exist
+// in Tree.
+func NewMultiProof(tr *avl.Tree, keys [][]byte) (MultiProof, error) {
+	if len(keys) < 1 {
+		return MultiProof{}, InvalidProofError.Wrapf("empty keys")
+	}
+
+	paths := map[string]struct{}{}
+	for _, key := range keys {
+		node, parents, err := tr.GetWithParents(key)
+		if err != nil {
+			return MultiProof{}, err
+		} else if node == nil {
+			return MultiProof{}, NodeNotFoundError.Wrapf("key=%x", key)
+		}
+
+		paths[string(node.Key())] = struct{}{}
+		for _, p := range parents {
+			paths[string(p.Key())] = struct{}{}
+		}
+	}
+
+	pt, err := newProofTree(tr, func(node avl.Node, isLeft bool) bool {
+		var key []byte
+		if isLeft {
+			key = node.LeftKey()
+		} else {
+			key = node.RightKey()
+		}
+
+		_, found := paths[string(key)]
+
+		return found
+	})
+	if err != nil {
+		return MultiProof{}, err
+	}
+
+	return MultiProof{keys: keys, tree: pt}, nil
+}
+
+// Keys returns the proven keys.
+func (pr MultiProof) Keys() [][]byte {
+	return pr.keys
+}
+
+// Tree returns the partial tree of proof.
+func (pr MultiProof) Tree() *ProofTree {
+	return pr.tree
+}
+
+// Prove reconstructs the root hash from proof and checks every key can be
+// found from the root. Prove returns the proven nodes by the order of keys.
+func (pr MultiProof) Prove(keys [][]byte, rootHash []byte, hashFunc NodeHashFunc) ([]ProofNode, error) {
+	err := pr.tree.proveRoot(rootHash, hashFunc, func(ProofNode, bool) error {
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make([]ProofNode, len(keys))
+	for i, key := range keys {
+		node, found := pr.tree.find(key)
+		if !found {
+			return nil, InvalidProofError.Wrapf("key not found in proof: key=%x", key)
+		}
+
+		nodes[i] = node
+	}
+
+	return nodes, nil
+}
+
+func (pr MultiProof) String() string {
+	b, _ := json.Marshal(pr)
+
+	return string(b)
+}
+
+func (pr MultiProof) MarshalBinary() ([]byte, error) {
+	return json.Marshal(pr)
+}
+
+func (pr MultiProof) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"keys": pr.keys,
+		"tree": pr.tree,
+	})
+}
@@ -0,0 +1,95 @@
+package hashable
+
+import (
+	"testing"
+
+	"github.com/spikeekips/avl"
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/xerrors"
+)
+
+type testMultiProof struct {
+	suite.Suite
+	prover ExampleProver
+	tr     *avl.Tree
+}
+
+func (t *testMultiProof) SetupTest() {
+	var keys []int
+	for i := 0; i < 40; i++ {
+		keys = append(keys, i)
+	}
+
+	tr, err := newTestHashedTree(keys, t.prover.GenerateNodeHash)
+	t.NoError(err)
+
+	t.tr = tr
+}
+
+func (t *testMultiProof) rootHash() []byte {
+	return t.tr.Root().(HashableNode).Hash()
+}
+
+func (t *testMultiProof) TestProve() {
+	keys := [][]byte{testKey(3), testKey(4), testKey(5), testKey(33), testKey(39)}
+
+	pr, err := NewMultiProof(t.tr, keys)
+	t.NoError(err)
+
+	nodes, err := pr.Prove(keys, t.rootHash(), t.prover.GenerateNodeHash)
+	t.NoError(err)
+	t.Equal(len(keys), len(nodes))
+
+	for i, key := range keys {
+		t.Equal(key, nodes[i].Key())
+
+		n, err := t.tr.Get(key)
+		t.NoError(err)
+		t.Equal(n.(HashableNode).Hash(), nodes[i].Hash())
+	}
+
+	// NOTE the shared parents are stored once.
+	var sum int
+	for _, key := range keys {
+		_, parents, err := t.tr.GetWithParents(key)
+		t.NoError(err)
+		sum += len(parents) + 1
+	}
+
+	var count int
+	pr.Tree().traverse(func(ProofNode) bool {
+		count++
+		return true
+	})
+
+	t.True(count < sum, "count=%d sum=%d", count, sum)
+}
+
+func (t *testMultiProof) TestUnknownKey() {
+	_, err := NewMultiProof(t.tr, [][]byte{testKey(3), testKey(99)})
+	t.True(xerrors.Is(err, NodeNotFoundError))
+}
+
+func (t *testMultiProof) TestKeyNotInProof() {
+	keys := [][]byte{testKey(3), testKey(33)}
+
+	pr, err := NewMultiProof(t.tr, keys)
+	t.NoError(err)
+
+	_, err = pr.Prove([][]byte{testKey(3), testKey(20)}, t.rootHash(), t.prover.GenerateNodeHash)
+	t.True(xerrors.Is(err, InvalidProofError))
+}
+
+func (t *testMultiProof) TestWrongRootHash() {
+	keys := [][]byte{testKey(3), testKey(33)}
+
+	pr, err := NewMultiProof(t.tr, keys)
+	t.NoError(err)
+
+	_, err = pr.Prove(keys, []byte("showme"), t.prover.GenerateNodeHash)
+	t.True(xerrors.Is(err, InvalidProofError))
+}
+
+func TestMultiProof(t *testing.T) {
+	suite.Run(t, new(testMultiProof))
+}
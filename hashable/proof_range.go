@@ -0,0 +1,132 @@
+package hashable
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/spikeekips/avl"
+)
+
+// RangeProof proves all the nodes in the key interval, [start, end]. RangeProof
+// reveals every node in the interval with the boundary nodes outside of the
+// interval, so the verifier can check nothing is left out.
+type RangeProof struct {
+	start []byte
+	end   []byte
+	tree  *ProofTree
+}
+
+// NewRangeProof makes RangeProof from hashable Tree.
+func NewRangeProof(tr *avl.Tree, start, end []byte) (RangeProof, error) {
+	if avl.CompareKey(start, end) > 0 {
+		return RangeProof{}, InvalidProofError.Wrapf("start is greater than end: start=%x end=%x", start, end)
+	}
+
+	pt, err := newProofTree(tr, func(node avl.Node, isLeft bool) bool {
+		if isLeft {
+			return avl.CompareKey(start, node.Key()) < 0
+		}
+
+		return avl.CompareKey(end, node.Key()) > 0
+	})
+	if err != nil {
+		return RangeProof{}, err
+	}
+
+	return RangeProof{start: start, end: end, tree: pt}, nil
+}
+
+// Start returns the start key of interval.
+func (pr RangeProof) Start() []byte {
+	return pr.start
+}
+
+// End returns the end key of interval.
+func (pr RangeProof) End() []byte {
+	return pr.end
+}
+
+// Tree returns the partial tree of proof.
+func (pr RangeProof) Tree() *ProofTree {
+	return pr.tree
+}
+
+// Prove reconstructs the root hash from proof and checks the leaf, which is
+// not revealed, is out of the interval. Prove returns the nodes in the
+// interval by key order.
+func (pr RangeProof) Prove(start, end, rootHash []byte, hashFunc NodeHashFunc) ([]ProofNode, error) {
+	if !avl.EqualKey(pr.start, start) || !avl.EqualKey(pr.end, end) {
+		return nil, InvalidProofError.Wrapf(
+			"interval not match: proof=[%x, %x] != [%x, %x]", pr.start, pr.end, start, end,
+		)
+	}
+
+	err := pr.tree.proveRoot(rootHash, hashFunc, func(node ProofNode, isLeft bool) error {
+		// NOTE the keys of left leaf are lesser than node, so node key should
+		// not be greater than start; and vice versa.
+		if isLeft && avl.CompareKey(node.key, start) > 0 {
+			return InvalidProofError.Wrapf("left leaf is not revealed in interval: key=%x", node.key)
+		} else if !isLeft && avl.CompareKey(node.key, end) < 0 {
+			return InvalidProofError.Wrapf("right leaf is not revealed in interval: key=%x", node.key)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var nodes []ProofNode
+	pr.tree.traverse(func(node ProofNode) bool {
+		if avl.CompareKey(node.key, start) >= 0 && avl.CompareKey(node.key, end) <= 0 {
+			nodes = append(nodes, node)
+		}
+
+		return true
+	})
+
+	return nodes, nil
+}
+
+// ProveNodes checks the given nodes are same with the complete nodes in
+// interval.
+func (pr RangeProof) ProveNodes(nodes []HashableNode, start, end, rootHash []byte, hashFunc NodeHashFunc) error {
+	proved, err := pr.Prove(start, end, rootHash, hashFunc)
+	if err != nil {
+		return err
+	}
+
+	if len(proved) != len(nodes) {
+		return InvalidProofError.Wrapf(
+			"number of nodes not match: proof=%d != nodes=%d", len(proved), len(nodes),
+		)
+	}
+
+	for i, n := range nodes {
+		if !avl.EqualKey(proved[i].key, n.Key()) || !bytes.Equal(proved[i].hash, n.Hash()) {
+			return InvalidProofError.Wrapf(
+				"node not match: proof=%x != node=%x", proved[i].key, n.Key(),
+			)
+		}
+	}
+
+	return nil
+}
+
+func (pr RangeProof) String() string {
+	b, _ := json.Marshal(pr)
+
+	return string(b)
+}
+
+func (pr RangeProof) MarshalBinary() ([]byte, error) {
+	return json.Marshal(pr)
+}
+
+func (pr RangeProof) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"start": pr.start,
+		"end":   pr.end,
+		"tree":  pr.tree,
+	})
+}
@@ -0,0 +1,113 @@
+package hashable
+
+import (
+	"testing"
+
+	"github.com/spikeekips/avl"
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/xerrors"
+)
+
+type testRangeProof struct {
+	suite.Suite
+	prover ExampleProver
+	tr     *avl.Tree
+}
+
+func (t *testRangeProof) SetupTest() {
+	var keys []int
+	for i := 10; i < 50; i += 2 {
+		keys = append(keys, i)
+	}
+
+	tr, err := newTestHashedTree(keys, t.prover.GenerateNodeHash)
+	t.NoError(err)
+
+	t.tr = tr
+}
+
+func (t *testRangeProof) rootHash() []byte {
+	return t.tr.Root().(HashableNode).Hash()
+}
+
+func (t *testRangeProof) TestProve() {
+	cases := []struct {
+		start    int
+		end      int
+		expected []int
+	}{
+		{start: 20, end: 30, expected: []int{20, 22, 24, 26, 28, 30}},
+		{start: 21, end: 29, expected: []int{22, 24, 26, 28}},
+		{start: 0, end: 13, expected: []int{10, 12}},
+		{start: 45, end: 99, expected: []int{46, 48}},
+		{start: 0, end: 99, expected: []int{10, 12, 14, 16, 18, 20, 22, 24, 26, 28, 30, 32, 34, 36, 38, 40, 42, 44, 46, 48}},
+		{start: 24, end: 24, expected: []int{24}},
+		{start: 25, end: 25, expected: nil},
+		{start: 60, end: 70, expected: nil},
+	}
+
+	for _, c := range cases {
+		start, end := testKey(c.start), testKey(c.end)
+
+		pr, err := NewRangeProof(t.tr, start, end)
+		t.NoError(err)
+
+		nodes, err := pr.Prove(start, end, t.rootHash(), t.prover.GenerateNodeHash)
+		t.NoError(err, "[%d, %d]", c.start, c.end)
+
+		t.Equal(len(c.expected), len(nodes), "[%d, %d]", c.start, c.end)
+		for i, k := range c.expected {
+			t.Equal(testKey(k), nodes[i].Key())
+		}
+	}
+}
+
+func (t *testRangeProof) TestLeftOut() {
+	start, end := testKey(20), testKey(40)
+
+	pr, err := NewRangeProof(t.tr, start, end)
+	t.NoError(err)
+
+	// NOTE remove the revealed leaf under root
+	t.NotNil(pr.tree.left)
+	pr.tree.left = nil
+
+	_, err = pr.Prove(start, end, t.rootHash(), t.prover.GenerateNodeHash)
+	t.True(xerrors.Is(err, InvalidProofError))
+}
+
+func (t *testRangeProof) TestProveNodes() {
+	start, end := testKey(20), testKey(26)
+
+	pr, err := NewRangeProof(t.tr, start, end)
+	t.NoError(err)
+
+	var nodes []HashableNode
+	for _, k := range []int{20, 22, 24, 26} {
+		n, err := t.tr.Get(testKey(k))
+		t.NoError(err)
+		nodes = append(nodes, n.(HashableNode))
+	}
+
+	t.NoError(pr.ProveNodes(nodes, start, end, t.rootHash(), t.prover.GenerateNodeHash))
+
+	// NOTE 24 is missing
+	missing := []HashableNode{nodes[0], nodes[1], nodes[3]}
+	err = pr.ProveNodes(missing, start, end, t.rootHash(), t.prover.GenerateNodeHash)
+	t.True(xerrors.Is(err, InvalidProofError))
+}
+
+func (t *testRangeProof) TestWrongInterval() {
+	pr, err := NewRangeProof(t.tr, testKey(20), testKey(26))
+	t.NoError(err)
+
+	_, err = pr.Prove(testKey(20), testKey(30), t.rootHash(), t.prover.GenerateNodeHash)
+	t.True(xerrors.Is(err, InvalidProofError))
+
+	_, err = NewRangeProof(t.tr, testKey(30), testKey(20))
+	t.True(xerrors.Is(err, InvalidProofError))
+}
+
+func TestRangeProof(t *testing.T) {
+	suite.Run(t, new(testRangeProof))
+}
@@ -0,0 +1,162 @@
+package hashable
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/spikeekips/avl"
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/xerrors"
+)
+
+type testPathProof struct {
+	suite.Suite
+	prover ExampleProver
+}
+
+func (t *testPathProof) newTree(n int) *avl.Tree {
+	keys := make([]int, n)
+	for i := 0; i < n; i++ {
+		keys[i] = i
+	}
+
+	tr, err := newTestHashedTree(keys, t.prover.GenerateNodeHash)
+	t.NoError(err)
+
+	return tr
+}
+
+func (t *testPathProof) proof(tr *avl.Tree, key []byte) PathProof {
+	node, parents, err := tr.GetWithParents(key)
+	t.NoError(err)
+	t.NotNil(node)
+
+	pr, err := NewPathProof(node.(HashableNode), toHashableNodes(parents))
+	t.NoError(err)
+
+	return pr
+}
+
+func (t *testPathProof) TestProveAll() {
+	tr := t.newTree(30)
+	rootHash := tr.Root().(HashableNode).Hash()
+
+	_ = tr.Traverse(func(node avl.Node) (bool, error) {
+		pr := t.proof(tr, node.Key())
+		t.NoError(pr.Prove(rootHash, t.prover.GenerateNodeHash), "key=%s", node.Key())
+
+		return true, nil
+	})
+}
+
+func (t *testPathProof) TestWrongRootHash() {
+	tr := t.newTree(10)
+
+	pr := t.proof(tr, []byte("005"))
+	err := pr.Prove([]byte("showme"), t.prover.GenerateNodeHash)
+	t.True(xerrors.Is(err, InvalidProofError))
+}
+
+func (t *testPathProof) TestFlippedDirection() {
+	tr := t.newTree(10)
+	rootHash := tr.Root().(HashableNode).Hash()
+
+	pr := t.proof(tr, []byte("005"))
+	t.True(len(pr.steps) > 0)
+
+	pr.steps[0].isLeft = !pr.steps[0].isLeft
+
+	err := pr.Prove(rootHash, t.prover.GenerateNodeHash)
+	t.True(xerrors.Is(err, InvalidProofError))
+}
+
+func (t *testPathProof) TestNotContiguousParents() {
+	tr := t.newTree(10)
+
+	node, parents, err := tr.GetWithParents([]byte("005"))
+	t.NoError(err)
+	t.True(len(parents) > 1)
+
+	// NOTE skip the nearest parent
+	_, err = NewPathProof(node.(HashableNode), toHashableNodes(parents[:len(parents)-1]))
+	t.True(xerrors.Is(err, InvalidProofError))
+}
+
+func (t *testPathProof) TestMisplacedKey() {
+	// NOTE 060 is misplaced in the left of 050, but the hashes are correctly
+	// generated.
+	root := &ExampleHashableMutableNode{key: []byte("050"), height: 1}
+	misplaced := &ExampleHashableMutableNode{key: []byte("060")}
+	t.NoError(root.SetLeft(misplaced))
+	t.NoError(SetTreeNodeHash(root, t.prover.GenerateNodeHash))
+
+	pr := PathProof{
+		node:  NewProofNode(misplaced),
+		steps: []ProofStep{{node: NewProofNode(root), isLeft: true}},
+	}
+
+	err := pr.Prove(root.Hash(), t.prover.GenerateNodeHash)
+	t.True(xerrors.Is(err, InvalidProofError))
+	t.Contains(err.Error(), "not bounded")
+
+	// NOTE claiming the other side does not match with the leaf hash.
+	pr.steps[0].isLeft = false
+	err = pr.Prove(root.Hash(), t.prover.GenerateNodeHash)
+	t.True(xerrors.Is(err, InvalidProofError))
+}
+
+func (t *testPathProof) TestSwappedLeafHash() {
+	// NOTE 060 is misplaced in the left of 050; the forged proof moves the
+	// left hash to the right hash, so 060 looks like the right leaf of 050.
+	root := &ExampleHashableMutableNode{key: []byte("050"), height: 1}
+	misplaced := &ExampleHashableMutableNode{key: []byte("060")}
+	t.NoError(root.SetLeft(misplaced))
+	t.NoError(SetTreeNodeHash(root, t.prover.GenerateNodeHash))
+
+	forged := NewProofNode(root)
+	forged.rightKey, forged.leftKey = forged.leftKey, nil
+	forged.rightHash, forged.leftHash = forged.leftHash, nil
+
+	pr := PathProof{
+		node:  NewProofNode(misplaced),
+		steps: []ProofStep{{node: forged, isLeft: false}},
+	}
+
+	err := pr.Prove(root.Hash(), t.prover.GenerateNodeHash)
+	t.True(xerrors.Is(err, InvalidProofError))
+	t.Contains(err.Error(), "node hash not match")
+}
+
+func TestPathProof(t *testing.T) {
+	suite.Run(t, new(testPathProof))
+}
+
+func newTestHashedTree(keys []int, hashFunc NodeHashFunc) (*avl.Tree, error) {
+	tg := avl.NewTreeGenerator()
+	for _, i := range keys {
+		if _, err := tg.Add(&ExampleHashableMutableNode{key: testKey(i)}); err != nil {
+			return nil, err
+		}
+	}
+
+	tr, err := tg.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := SetTreeNodeHash(tr.Root().(HashableMutableNode), hashFunc); err != nil {
+		return nil, err
+	}
+
+	return tr, nil
+}
+
+func testKey(i int) []byte {
+	return []byte(fmt.Sprintf("%03d", i))
+}
+
+func toHashableNodes(nodes []avl.Node) []HashableNode {
+	hs, _ := ToHashableNodes(nodes)
+
+	return hs
+}
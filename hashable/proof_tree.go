@@ -0,0 +1,225 @@
+package hashable
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/spikeekips/avl"
+)
+
+// ProofTree is the partial tree of hashable tree. The leaf of ProofTree node
+// can be revealed as ProofTree or not; the leaf, which is not revealed, is
+// presented only by the leaf hash of it's parent.
+type ProofTree struct {
+	node  ProofNode
+	left  *ProofTree
+	right *ProofTree
+}
+
+// proofTreeRevealFunc decides whether the left or right leaf of node should be
+// revealed in ProofTree.
+type proofTreeRevealFunc func(node avl.Node, isLeft bool) bool
+
+// proofTreePrunedFunc is called for the leaf, which is not revealed, in
+// ProofTree.prove(). If the leaf should be revealed, it returns error.
+type proofTreePrunedFunc func(node ProofNode, isLeft bool) error
+
+// newProofTree builds ProofTree from the root of Tree.
+func newProofTree(tr *avl.Tree, reveal proofTreeRevealFunc) (*ProofTree, error) {
+	if tr.Root() == nil {
+		return nil, InvalidProofError.Wrapf("empty tree")
+	}
+
+	return newProofTreeFromNode(tr, tr.Root(), reveal)
+}
+
+func newProofTreeFromNode(tr *avl.Tree, node avl.Node, reveal proofTreeRevealFunc) (*ProofTree, error) {
+	hn, ok := node.(HashableNode)
+	if !ok {
+		return nil, NotHashableNodeError.Wrapf("key=%x type=%T", node.Key(), node)
+	}
+
+	pt := &ProofTree{node: NewProofNode(hn)}
+
+	for _, isLeft := range []bool{true, false} {
+		var key []byte
+		if isLeft {
+			key = node.LeftKey()
+		} else {
+			key = node.RightKey()
+		}
+
+		if key == nil || !reveal(node, isLeft) {
+			continue
+		}
+
+		leaf, err := tr.NodePool().Get(key)
+		if err != nil {
+			return nil, err
+		} else if leaf == nil {
+			return nil, avl.NodeNotFoundInPoolError.Wrapf("leaf key=%x", key)
+		}
+
+		lpt, err := newProofTreeFromNode(tr, leaf, reveal)
+		if err != nil {
+			return nil, err
+		}
+
+		if isLeft {
+			pt.left = lpt
+		} else {
+			pt.right = lpt
+		}
+	}
+
+	return pt, nil
+}
+
+// Node returns the node.
+func (pt *ProofTree) Node() ProofNode {
+	return pt.node
+}
+
+// Left returns the revealed left leaf. If left leaf is not revealed, Left()
+// returns nil.
+func (pt *ProofTree) Left() *ProofTree {
+	return pt.left
+}
+
+// Right returns the revealed right leaf. If right leaf is not revealed,
+// Right() returns nil.
+func (pt *ProofTree) Right() *ProofTree {
+	return pt.right
+}
+
+func (pt *ProofTree) leaf(isLeft bool) *ProofTree {
+	if isLeft {
+		return pt.left
+	}
+
+	return pt.right
+}
+
+// prove checks the hashes and the key ordering of ProofTree recursively. The
+// key of node must be between low and high; nil low or high means no bound.
+func (pt *ProofTree) prove(low, high []byte, hashFunc NodeHashFunc, pruned proofTreePrunedFunc) error {
+	if (low != nil && avl.CompareKey(pt.node.key, low) <= 0) ||
+		(high != nil && avl.CompareKey(pt.node.key, high) >= 0) {
+		return InvalidProofError.Wrapf(
+			"key is not bounded: key=%x low=%x high=%x", pt.node.key, low, high,
+		)
+	}
+
+	if err := pt.node.prove(hashFunc); err != nil {
+		return err
+	}
+
+	for _, isLeft := range []bool{true, false} {
+		h := pt.node.leafHash(isLeft)
+		leaf := pt.leaf(isLeft)
+
+		if leaf == nil {
+			if h == nil {
+				continue
+			}
+
+			if err := pruned(pt.node, isLeft); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if !bytes.Equal(leaf.node.hash, h) {
+			return InvalidProofError.Wrapf(
+				"leaf hash not match: parent=%x leaf=%x isLeft=%v",
+				pt.node.key, leaf.node.key, isLeft,
+			)
+		}
+
+		if leaf.node.height >= pt.node.height {
+			return InvalidProofError.Wrapf(
+				"parent height must be greater than leaf: leaf=%x(%d) parent=%x(%d)",
+				leaf.node.key, leaf.node.height, pt.node.key, pt.node.height,
+			)
+		}
+
+		var err error
+		if isLeft {
+			err = leaf.prove(low, pt.node.key, hashFunc, pruned)
+		} else {
+			err = leaf.prove(pt.node.key, high, hashFunc, pruned)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// proveRoot checks ProofTree with the root hash.
+func (pt *ProofTree) proveRoot(rootHash []byte, hashFunc NodeHashFunc, pruned proofTreePrunedFunc) error {
+	if pt == nil {
+		return InvalidProofError.Wrapf("empty proof tree")
+	}
+
+	if err := pt.prove(nil, nil, hashFunc, pruned); err != nil {
+		return err
+	}
+
+	if !bytes.Equal(pt.node.hash, rootHash) {
+		return InvalidProofError.Wrapf(
+			"top node hash not match with root hash: topnode.hash=%x != root.hash=%x",
+			pt.node.hash,
+			rootHash,
+		)
+	}
+
+	return nil
+}
+
+// traverse traverses the revealed nodes by key order.
+func (pt *ProofTree) traverse(f func(ProofNode) bool) bool {
+	if pt == nil {
+		return true
+	}
+
+	if !pt.left.traverse(f) {
+		return false
+	}
+
+	if !f(pt.node) {
+		return false
+	}
+
+	return pt.right.traverse(f)
+}
+
+// find searches the revealed node by key like Tree.Get().
+func (pt *ProofTree) find(key []byte) (ProofNode, bool) {
+	for pt != nil {
+		c := avl.CompareKey(key, pt.node.key)
+		if c == 0 {
+			return pt.node, true
+		}
+
+		pt = pt.leaf(c < 0)
+	}
+
+	return ProofNode{}, false
+}
+
+func (pt *ProofTree) MarshalJSON() ([]byte, error) {
+	m := map[string]interface{}{
+		"node": pt.node,
+	}
+	if pt.left != nil {
+		m["left"] = pt.left
+	}
+	if pt.right != nil {
+		m["right"] = pt.right
+	}
+
+	return json.Marshal(m)
+}
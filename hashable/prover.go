@@ -18,9 +18,14 @@ type Proof interface {
 type Prover interface {
 	Proof(node HashableNode, parents []HashableNode) (Proof, error)
 	GenerateNodeHash(HashableNode) ([]byte, error)
-	Prove(Proof, rootHash []byte) error
+	Prove(proof Proof, rootHash []byte) error
 }
 
+// NodeHashFunc generates the hash of node. The hash should bind each leaf hash
+// to it's side; if the left hash and the right hash are just concatenated, the
+// node with one leaf has the same hash whichever side the leaf is, and the
+// proofs can be forged by moving the leaf hash to the other side. Write the
+// presence marker or the length before each hash.
 type NodeHashFunc func(HashableNode) ([]byte, error)
 
 func SetTreeNodeHash(node HashableMutableNode, hashFunc NodeHashFunc) error {
@@ -0,0 +1,49 @@
+package hashable
+
+import (
+	"github.com/spikeekips/avl"
+)
+
+var (
+	NodeNotFoundError    = avl.NewWrapError("node not found")
+	NotHashableNodeError = avl.NewWrapError("not HashableNode")
+)
+
+// ProveKey finds the node by key from Tree and generates the proof of node by
+// Prover. The node and it's parents are passed to Prover.Proof() by the order
+// of Tree.GetWithParents().
+func ProveKey(tr *avl.Tree, prover Prover, key []byte) (Proof, error) {
+	node, parents, err := tr.GetWithParents(key)
+	if err != nil {
+		return nil, err
+	} else if node == nil {
+		return nil, NodeNotFoundError.Wrapf("key=%x", key)
+	}
+
+	hn, ok := node.(HashableNode)
+	if !ok {
+		return nil, NotHashableNodeError.Wrapf("key=%x type=%T", node.Key(), node)
+	}
+
+	hparents, err := ToHashableNodes(parents)
+	if err != nil {
+		return nil, err
+	}
+
+	return prover.Proof(hn, hparents)
+}
+
+// ToHashableNodes converts the slice of avl.Node to the slice of HashableNode.
+func ToHashableNodes(nodes []avl.Node) ([]HashableNode, error) {
+	hs := make([]HashableNode, len(nodes))
+	for i, n := range nodes {
+		h, ok := n.(HashableNode)
+		if !ok {
+			return nil, NotHashableNodeError.Wrapf("key=%x type=%T", n.Key(), n)
+		}
+
+		hs[i] = h
+	}
+
+	return hs, nil
+}
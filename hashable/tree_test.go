@@ -6,6 +6,7 @@ import (
 
 	"github.com/spikeekips/avl"
 	"github.com/stretchr/testify/suite"
+	"golang.org/x/xerrors"
 )
 
 type testTree struct {
@@ -146,6 +147,93 @@ func (t *testTree) TestHashNodeProof() {
 	}
 }
 
+func (t *testTree) TestProveKey() {
+	prover := ExampleProver{}
+
+	tr, err := newTestHashedTree([]int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, prover.GenerateNodeHash)
+	t.NoError(err)
+
+	rootHash := tr.Root().(HashableNode).Hash()
+
+	for i := 0; i < 10; i++ {
+		pr, err := ProveKey(tr, prover, testKey(i))
+		t.NoError(err)
+		t.NoError(prover.Prove(pr, rootHash))
+	}
+}
+
+func (t *testTree) TestProveKeyNotFound() {
+	prover := ExampleProver{}
+
+	tr, err := newTestHashedTree([]int{0, 1, 2}, prover.GenerateNodeHash)
+	t.NoError(err)
+
+	_, err = ProveKey(tr, prover, testKey(9))
+	t.True(xerrors.Is(err, NodeNotFoundError))
+}
+
+func (t *testTree) TestProveKeyNotHashable() {
+	prover := ExampleProver{}
+
+	np := avl.NewMapNodePool(nil)
+	_ = np.Set(&testPlainNode{key: testKey(1), height: 1, left: testKey(0)})
+	_ = np.Set(&testPlainNode{key: testKey(0)})
+
+	tr, err := avl.NewTree(testKey(1), np)
+	t.NoError(err)
+
+	_, err = ProveKey(tr, prover, testKey(0))
+	t.True(xerrors.Is(err, NotHashableNodeError))
+}
+
+func (t *testTree) TestProveKeyReadOnly() {
+	prover := ExampleProver{}
+
+	tr, err := newTestHashedTree([]int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, prover.GenerateNodeHash)
+	t.NoError(err)
+
+	rtr := tr.ReadOnly()
+
+	_, ok := rtr.Root().(HashableMutableNode)
+	t.False(ok)
+
+	rootHash := rtr.Root().(HashableNode).Hash()
+	t.Equal(tr.Root().(HashableNode).Hash(), rootHash)
+
+	// NOTE the returned hash is the copy
+	rtr.Root().(HashableNode).Hash()[0] ^= 0xff
+	t.Equal(rootHash, tr.Root().(HashableNode).Hash())
+
+	for i := 0; i < 10; i++ {
+		pr, err := ProveKey(rtr, prover, testKey(i))
+		t.NoError(err)
+		t.NoError(prover.Prove(pr, rootHash))
+	}
+}
+
 func TestTree(t *testing.T) {
 	suite.Run(t, new(testTree))
 }
+
+type testPlainNode struct {
+	key    []byte
+	height int16
+	left   []byte
+	right  []byte
+}
+
+func (tn *testPlainNode) Key() []byte {
+	return tn.key
+}
+
+func (tn *testPlainNode) Height() int16 {
+	return tn.height
+}
+
+func (tn *testPlainNode) LeftKey() []byte {
+	return tn.left
+}
+
+func (tn *testPlainNode) RightKey() []byte {
+	return tn.right
+}
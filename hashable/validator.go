@@ -0,0 +1,64 @@
+package hashable
+
+import (
+	"bytes"
+
+	"github.com/spikeekips/avl"
+)
+
+var (
+	InvalidNodeHashError = avl.NewWrapError("invalid node hash")
+)
+
+// NodeHashValidator returns avl.NodeValidatorFunc, which generates the hash of
+// node again by hashFunc and compares it with the stored Hash(), LeftHash()
+// and RightHash(). With avl.TreeValidator, the hashes of the entire tree are
+// checked from the bottom to the top.
+func NodeHashValidator(hashFunc NodeHashFunc) avl.NodeValidatorFunc {
+	return func(node, left, right avl.Node) error {
+		hn, ok := node.(HashableNode)
+		if !ok {
+			return NotHashableNodeError.Wrapf("key=%x type=%T", node.Key(), node)
+		}
+
+		for _, l := range []struct {
+			leaf   avl.Node
+			hash   []byte
+			isLeft bool
+		}{
+			{leaf: left, hash: hn.LeftHash(), isLeft: true},
+			{leaf: right, hash: hn.RightHash(), isLeft: false},
+		} {
+			var leafHash []byte
+			if l.leaf != nil {
+				hl, ok := l.leaf.(HashableNode)
+				if !ok {
+					return NotHashableNodeError.Wrapf("key=%x type=%T", l.leaf.Key(), l.leaf)
+				}
+				leafHash = hl.Hash()
+			}
+
+			if !bytes.Equal(leafHash, l.hash) {
+				return InvalidNodeHashError.Wrapf(
+					"leaf hash not match: isLeft=%v leaf.hash=%x != stored=%x", l.isLeft, leafHash, l.hash,
+				)
+			}
+		}
+
+		h, err := hashFunc(hn)
+		if err != nil {
+			return err
+		}
+
+		if !bytes.Equal(h, hn.Hash()) {
+			return InvalidNodeHashError.Wrapf("hash not match: generated=%x != stored=%x", h, hn.Hash())
+		}
+
+		return nil
+	}
+}
+
+// IsValidTreeHash validates Tree with NodeHashValidator.
+func IsValidTreeHash(tr *avl.Tree, hashFunc NodeHashFunc) error {
+	return avl.NewTreeValidator(tr).AddNodeValidator(NodeHashValidator(hashFunc)).IsValid()
+}
@@ -0,0 +1,70 @@
+package hashable
+
+import (
+	"testing"
+
+	"github.com/spikeekips/avl"
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/xerrors"
+)
+
+type testNodeHashValidator struct {
+	suite.Suite
+	prover ExampleProver
+	tr     *avl.Tree
+}
+
+func (t *testNodeHashValidator) SetupTest() {
+	keys := make([]int, 30)
+	for i := range keys {
+		keys[i] = i
+	}
+
+	tr, err := newTestHashedTree(keys, t.prover.GenerateNodeHash)
+	t.NoError(err)
+
+	t.tr = tr
+}
+
+func (t *testNodeHashValidator) TestValid() {
+	t.NoError(IsValidTreeHash(t.tr, t.prover.GenerateNodeHash))
+}
+
+func (t *testNodeHashValidator) TestMismatched() {
+	tampered := map[string]bool{}
+	for _, i := range []int{3, 17, 28} {
+		n, err := t.tr.Get(testKey(i))
+		t.NoError(err)
+
+		n.(*ExampleHashableMutableNode).value = 33
+		tampered[string(n.Key())] = true
+	}
+
+	err := IsValidTreeHash(t.tr, t.prover.GenerateNodeHash)
+	t.True(xerrors.Is(err, avl.InvalidTreeError))
+
+	var ne avl.NodeValidatorError
+	t.True(xerrors.As(err, &ne))
+	t.Equal(len(tampered), len(ne.Keys))
+
+	for i, key := range ne.Keys {
+		t.True(tampered[string(key)], "key=%s", key)
+		t.True(xerrors.Is(ne.Errs[i], InvalidNodeHashError))
+	}
+}
+
+func (t *testNodeHashValidator) TestEmptyHash() {
+	n, err := t.tr.Get(testKey(3))
+	t.NoError(err)
+	n.(*ExampleHashableMutableNode).ResetHash()
+
+	err = IsValidTreeHash(t.tr, t.prover.GenerateNodeHash)
+
+	var ne avl.NodeValidatorError
+	t.True(xerrors.As(err, &ne))
+	t.Contains(ne.Keys, testKey(3))
+}
+
+func TestNodeHashValidator(t *testing.T) {
+	suite.Run(t, new(testNodeHashValidator))
+}
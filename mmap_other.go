@@ -0,0 +1,24 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package avl
+
+import (
+	"io"
+	"os"
+)
+
+// mmapFile reads the whole file to memory; memory mapping is not supported in
+// this platform.
+func mmapFile(f *os.File, size int) ([]byte, error) {
+	b := make([]byte, size)
+	if _, err := io.ReadFull(io.NewSectionReader(f, 0, int64(size)), b); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+func munmapFile([]byte) error {
+	return nil
+}
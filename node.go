@@ -47,6 +47,20 @@ func CompareKey(a, b []byte) int {
 
 // IsValidNode checks node is valid and well defined.
 func IsValidNode(node, left, right Node) error {
+	if err := isValidNodeKey(node, left, right); err != nil {
+		return err
+	}
+
+	if err := isValidNodeBalance(node, left, right); err != nil {
+		return err
+	}
+
+	return isValidNodeHeight(node, left, right)
+}
+
+// isValidNodeKey checks the key of node is not empty and the keys of leaves
+// are correctly ordered.
+func isValidNodeKey(node, left, right Node) error {
 	// check empty key
 	if node.Key() == nil || len(node.Key()) < 1 {
 		return InvalidNodeError.Wrapf("key is empty")
@@ -66,29 +80,42 @@ func IsValidNode(node, left, right Node) error {
 		)
 	}
 
-	// check height
+	return nil
+}
+
+// isValidNodeBalance checks the heights of leaves are balanced.
+func isValidNodeBalance(_, left, right Node) error {
+	if isLeft, violated := isSiblingNodesViolated(left, right); violated {
+		return InvalidNodeError.Wrapf("left or right leaf is violated; isLeft=%v", isLeft)
+	}
+
+	return nil
+}
+
+// isValidNodeHeight checks the height of node is +1 by the higher leaf.
+func isValidNodeHeight(node, left, right Node) error {
 	if left == nil && right == nil {
 		if node.Height() != 0 {
 			return InvalidNodeError.Wrapf("height must be 0 without leaf; height=%d", node.Height())
 		}
-	} else if isLeft, violated := isSiblingNodesViolated(left, right); violated {
-		return InvalidNodeError.Wrapf("left or right leaf is violated; isLeft=%v", isLeft)
-	} else {
-		var baseHeight int16 = -1
-		if left != nil {
-			baseHeight = left.Height()
-		}
 
-		if right != nil && right.Height() > baseHeight {
-			baseHeight = right.Height()
-		}
+		return nil
+	}
 
-		if node.Height() != baseHeight+1 {
-			return InvalidNodeError.Wrapf(
-				"height must be +1 by leaf; left_or_right=%d height=%d",
-				baseHeight, node.Height(),
-			)
-		}
+	var baseHeight int16 = -1
+	if left != nil {
+		baseHeight = left.Height()
+	}
+
+	if right != nil && right.Height() > baseHeight {
+		baseHeight = right.Height()
+	}
+
+	if node.Height() != baseHeight+1 {
+		return InvalidNodeError.Wrapf(
+			"height must be +1 by leaf; left_or_right=%d height=%d",
+			baseHeight, node.Height(),
+		)
 	}
 
 	return nil
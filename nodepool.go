@@ -6,6 +6,10 @@ import (
 	"golang.org/x/xerrors"
 )
 
+var (
+	NotAtomicNodePoolError = NewWrapError("NodePool does not support Apply")
+)
+
 // NodePool is the container of node in Tree.
 type NodePool interface {
 	// Get returns node by key. The returned error is for the external storage
@@ -21,6 +25,135 @@ type NodePool interface {
 	Traverse(NodeTraverseFunc) error
 }
 
+// DeletableNodePool is the NodePool, which can remove node.
+type DeletableNodePool interface {
+	NodePool
+
+	// Delete removes node by key. If node is not found, Delete() does
+	// nothing.
+	Delete(key []byte) error
+}
+
+// BatchNodePool is the NodePool, which can get and set the multiple nodes at
+// once. The storage, which supports the batch operation, can reduce the round
+// trips.
+type BatchNodePool interface {
+	NodePool
+
+	// GetMany returns the nodes by keys. The returned slice has the same
+	// length and order with keys; if node is not found, it's element is nil.
+	GetMany(keys [][]byte) ([]Node, error)
+
+	// SetMany inserts the nodes.
+	SetMany(nodes []Node) error
+}
+
+// AtomicNodePool is the NodePool, which applies the multiple changes at once.
+// After crash or error, the all changes of Apply() or none of them are
+// applied. The decorators, like ChecksumNodePool, implement Apply(), but it
+// returns NotAtomicNodePoolError if the inner NodePool is not AtomicNodePool.
+type AtomicNodePool interface {
+	NodePool
+
+	// Apply inserts the nodes and then removes the nodes of deleted keys.
+	Apply(nodes []Node, deleted [][]byte) error
+}
+
+// CountableNodePool is the NodePool, which knows it's nodes without loading
+// them.
+type CountableNodePool interface {
+	NodePool
+
+	// Has checks whether node exists by key.
+	Has(key []byte) (bool, error)
+
+	// Len returns the number of nodes.
+	Len() (int, error)
+}
+
+// GetNodes returns the nodes by keys. If NodePool is BatchNodePool, GetMany()
+// is used. Like BatchNodePool.GetMany(), the nil key or the unknown key
+// returns nil node.
+func GetNodes(np NodePool, keys [][]byte) ([]Node, error) {
+	if bp, ok := np.(BatchNodePool); ok {
+		return bp.GetMany(keys)
+	}
+
+	nodes := make([]Node, len(keys))
+	for i, key := range keys {
+		if key == nil {
+			continue
+		}
+
+		node, err := np.Get(key)
+		if err != nil {
+			return nil, err
+		}
+		nodes[i] = node
+	}
+
+	return nodes, nil
+}
+
+// SetNodes inserts the nodes. If NodePool is BatchNodePool, SetMany() is used.
+func SetNodes(np NodePool, nodes []Node) error {
+	if bp, ok := np.(BatchNodePool); ok {
+		return bp.SetMany(nodes)
+	}
+
+	for _, node := range nodes {
+		if err := np.Set(node); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ApplyNodes applies the changes by AtomicNodePool.Apply(). If NodePool is not
+// AtomicNodePool, it returns NotAtomicNodePoolError.
+func ApplyNodes(np NodePool, nodes []Node, deleted [][]byte) error {
+	ap, ok := np.(AtomicNodePool)
+	if !ok {
+		return NotAtomicNodePoolError.Wrapf("type=%T", np)
+	}
+
+	return ap.Apply(nodes, deleted)
+}
+
+// HasNode checks whether node exists by key. If NodePool is not
+// CountableNodePool, node is loaded by Get().
+func HasNode(np NodePool, key []byte) (bool, error) {
+	if cp, ok := np.(CountableNodePool); ok {
+		return cp.Has(key)
+	}
+
+	node, err := np.Get(key)
+	if err != nil {
+		return false, err
+	}
+
+	return node != nil, nil
+}
+
+// CountNodes returns the number of nodes. If NodePool is not
+// CountableNodePool, it traverses the all nodes.
+func CountNodes(np NodePool) (int, error) {
+	if cp, ok := np.(CountableNodePool); ok {
+		return cp.Len()
+	}
+
+	var n int
+	if err := np.Traverse(func(Node) (bool, error) {
+		n++
+		return true, nil
+	}); err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
+
 // SyncMapNodePool uses sync.Map.
 type SyncMapNodePool struct {
 	m *sync.Map
@@ -50,6 +183,66 @@ func (mn *SyncMapNodePool) Set(node Node) error {
 	return nil
 }
 
+func (mn *SyncMapNodePool) Delete(key []byte) error {
+	mn.m.Delete(string(key))
+	return nil
+}
+
+func (mn *SyncMapNodePool) Has(key []byte) (bool, error) {
+	_, found := mn.m.Load(string(key))
+	return found, nil
+}
+
+// Len counts the nodes by sync.Map.Range(), sync.Map does not have length.
+func (mn *SyncMapNodePool) Len() (int, error) {
+	var n int
+	mn.m.Range(func(_, _ interface{}) bool {
+		n++
+		return true
+	})
+
+	return n, nil
+}
+
+func (mn *SyncMapNodePool) GetMany(keys [][]byte) ([]Node, error) {
+	nodes := make([]Node, len(keys))
+	for i, key := range keys {
+		if key == nil {
+			continue
+		}
+
+		node, err := mn.Get(key)
+		if err != nil {
+			return nil, err
+		}
+		nodes[i] = node
+	}
+
+	return nodes, nil
+}
+
+func (mn *SyncMapNodePool) SetMany(nodes []Node) error {
+	for _, node := range nodes {
+		mn.m.Store(string(node.Key()), node)
+	}
+
+	return nil
+}
+
+// Apply applies the changes. The other goroutine can see the part of changes
+// while applying.
+func (mn *SyncMapNodePool) Apply(nodes []Node, deleted [][]byte) error {
+	for _, node := range nodes {
+		mn.m.Store(string(node.Key()), node)
+	}
+
+	for _, key := range deleted {
+		mn.m.Delete(string(key))
+	}
+
+	return nil
+}
+
 func (mn *SyncMapNodePool) Traverse(f NodeTraverseFunc) error {
 	var err error
 	mn.m.Range(func(_, value interface{}) bool {
@@ -97,6 +290,53 @@ func (mn *MapNodePool) Set(node Node) error {
 	return nil
 }
 
+func (mn *MapNodePool) Delete(key []byte) error {
+	delete(mn.m, string(key))
+
+	return nil
+}
+
+func (mn *MapNodePool) Has(key []byte) (bool, error) {
+	_, found := mn.m[string(key)]
+
+	return found, nil
+}
+
+func (mn *MapNodePool) Len() (int, error) {
+	return len(mn.m), nil
+}
+
+func (mn *MapNodePool) GetMany(keys [][]byte) ([]Node, error) {
+	nodes := make([]Node, len(keys))
+	for i, key := range keys {
+		if node, found := mn.m[string(key)]; found && key != nil {
+			nodes[i] = node
+		}
+	}
+
+	return nodes, nil
+}
+
+func (mn *MapNodePool) SetMany(nodes []Node) error {
+	for _, node := range nodes {
+		mn.m[string(node.Key())] = node
+	}
+
+	return nil
+}
+
+func (mn *MapNodePool) Apply(nodes []Node, deleted [][]byte) error {
+	for _, node := range nodes {
+		mn.m[string(node.Key())] = node
+	}
+
+	for _, key := range deleted {
+		delete(mn.m, string(key))
+	}
+
+	return nil
+}
+
 func (mn *MapNodePool) Traverse(f NodeTraverseFunc) error {
 	for _, node := range mn.m {
 		if keep, err := f(node); err != nil {
@@ -141,6 +381,66 @@ func (mn *MapMutableNodePool) Set(node Node) error {
 	return nil
 }
 
+func (mn *MapMutableNodePool) Delete(key []byte) error {
+	delete(mn.m, string(key))
+
+	return nil
+}
+
+func (mn *MapMutableNodePool) Has(key []byte) (bool, error) {
+	_, found := mn.m[string(key)]
+
+	return found, nil
+}
+
+func (mn *MapMutableNodePool) Len() (int, error) {
+	return len(mn.m), nil
+}
+
+func (mn *MapMutableNodePool) GetMany(keys [][]byte) ([]Node, error) {
+	nodes := make([]Node, len(keys))
+	for i, key := range keys {
+		if node, found := mn.m[string(key)]; found && key != nil {
+			nodes[i] = node
+		}
+	}
+
+	return nodes, nil
+}
+
+// SetMany inserts the nodes. If one of nodes is not MutableNode, nothing is
+// inserted.
+func (mn *MapMutableNodePool) SetMany(nodes []Node) error {
+	ns := make([]MutableNode, len(nodes))
+	for i, node := range nodes {
+		n, ok := node.(MutableNode)
+		if !ok {
+			return xerrors.Errorf("not MutableNode; %T", node)
+		}
+		ns[i] = n
+	}
+
+	for _, n := range ns {
+		mn.m[string(n.Key())] = n
+	}
+
+	return nil
+}
+
+// Apply applies the changes. Like SetMany(), if one of nodes is not
+// MutableNode, nothing is applied.
+func (mn *MapMutableNodePool) Apply(nodes []Node, deleted [][]byte) error {
+	if err := mn.SetMany(nodes); err != nil {
+		return err
+	}
+
+	for _, key := range deleted {
+		delete(mn.m, string(key))
+	}
+
+	return nil
+}
+
 func (mn *MapMutableNodePool) Traverse(f NodeTraverseFunc) error {
 	for _, node := range mn.m {
 		if keep, err := f(node); err != nil {
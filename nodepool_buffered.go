@@ -0,0 +1,312 @@
+package avl
+
+import (
+	"sync"
+)
+
+// CommittableNodePool is the NodePool, which makes the changes durable by
+// Commit(), like FileNodePool.
+type CommittableNodePool interface {
+	NodePool
+	Commit() error
+}
+
+// BufferedNodePool keeps the nodes of Set() and Delete() in memory until
+// Flush() or Commit(). Get() finds node from the buffer first and then from the
+// inner NodePool.
+//
+// Flush() writes the buffered changes atomically by AtomicNodePool.Apply(), so
+// the inner NodePool should implement AtomicNodePool, like FileNodePool,
+// PageNodePool and the decorators over them; otherwise Flush() returns
+// NotAtomicNodePoolError and the buffer is kept.
+//
+// BufferedNodePool assumes the inner NodePool stores node by it's key.
+type BufferedNodePool struct {
+	sync.RWMutex
+	np      NodePool
+	keys    []string // keys of buffered nodes in the order of Set()
+	nodes   map[string]Node
+	deleted map[string]struct{}
+}
+
+func NewBufferedNodePool(np NodePool) *BufferedNodePool {
+	return &BufferedNodePool{
+		np:      np,
+		nodes:   map[string]Node{},
+		deleted: map[string]struct{}{},
+	}
+}
+
+// NodePool returns the inner NodePool.
+func (bp *BufferedNodePool) NodePool() NodePool {
+	return bp.np
+}
+
+// load returns the buffered node. If node is deleted, it returns nil node and
+// true.
+func (bp *BufferedNodePool) load(key []byte) (Node, bool) {
+	if _, found := bp.deleted[string(key)]; found {
+		return nil, true
+	}
+
+	node, found := bp.nodes[string(key)]
+
+	return node, found
+}
+
+func (bp *BufferedNodePool) Get(key []byte) (Node, error) {
+	bp.RLock()
+	node, found := bp.load(key)
+	bp.RUnlock()
+
+	if found {
+		return node, nil
+	}
+
+	return bp.np.Get(key)
+}
+
+func (bp *BufferedNodePool) GetMany(keys [][]byte) ([]Node, error) {
+	nodes := make([]Node, len(keys))
+
+	var missing [][]byte
+	var indices []int
+
+	bp.RLock()
+	for i, key := range keys {
+		if key == nil {
+			continue
+		}
+
+		if node, found := bp.load(key); found {
+			nodes[i] = node
+			continue
+		}
+
+		missing = append(missing, key)
+		indices = append(indices, i)
+	}
+	bp.RUnlock()
+
+	if len(missing) < 1 {
+		return nodes, nil
+	}
+
+	found, err := GetNodes(bp.np, missing)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range found {
+		nodes[indices[i]] = found[i]
+	}
+
+	return nodes, nil
+}
+
+func (bp *BufferedNodePool) Set(node Node) error {
+	bp.Lock()
+	defer bp.Unlock()
+
+	bp.set(node)
+
+	return nil
+}
+
+func (bp *BufferedNodePool) SetMany(nodes []Node) error {
+	bp.Lock()
+	defer bp.Unlock()
+
+	for _, node := range nodes {
+		bp.set(node)
+	}
+
+	return nil
+}
+
+func (bp *BufferedNodePool) set(node Node) {
+	key := string(node.Key())
+
+	delete(bp.deleted, key)
+
+	if _, found := bp.nodes[key]; !found {
+		bp.keys = append(bp.keys, key)
+	}
+	bp.nodes[key] = node
+}
+
+// Delete buffers the deletion. The inner NodePool should implement
+// DeletableNodePool.
+func (bp *BufferedNodePool) Delete(key []byte) error {
+	if _, ok := bp.np.(DeletableNodePool); !ok {
+		return NotDeletableNodePoolError.Wrapf("type=%T", bp.np)
+	}
+
+	bp.Lock()
+	defer bp.Unlock()
+
+	if _, found := bp.nodes[string(key)]; found {
+		delete(bp.nodes, string(key))
+		bp.keys = bp.removeKey(string(key))
+	}
+
+	bp.deleted[string(key)] = struct{}{}
+
+	return nil
+}
+
+func (bp *BufferedNodePool) removeKey(key string) []string {
+	for i := range bp.keys {
+		if bp.keys[i] == key {
+			return append(bp.keys[:i], bp.keys[i+1:]...)
+		}
+	}
+
+	return bp.keys
+}
+
+func (bp *BufferedNodePool) Has(key []byte) (bool, error) {
+	bp.RLock()
+	node, found := bp.load(key)
+	bp.RUnlock()
+
+	if found {
+		return node != nil, nil
+	}
+
+	return HasNode(bp.np, key)
+}
+
+// Len returns the number of nodes including the buffered changes.
+func (bp *BufferedNodePool) Len() (int, error) {
+	bp.RLock()
+	defer bp.RUnlock()
+
+	n, err := CountNodes(bp.np)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, key := range bp.keys {
+		if found, err := HasNode(bp.np, []byte(key)); err != nil {
+			return 0, err
+		} else if !found {
+			n++
+		}
+	}
+
+	for key := range bp.deleted {
+		if found, err := HasNode(bp.np, []byte(key)); err != nil {
+			return 0, err
+		} else if found {
+			n--
+		}
+	}
+
+	return n, nil
+}
+
+// Traverse traverses the buffered nodes first and then the nodes of inner
+// NodePool, which are not buffered.
+func (bp *BufferedNodePool) Traverse(f NodeTraverseFunc) error {
+	bp.RLock()
+	nodes := make([]Node, len(bp.keys))
+	for i, key := range bp.keys {
+		nodes[i] = bp.nodes[key]
+	}
+
+	skip := map[string]struct{}{}
+	for key := range bp.nodes {
+		skip[key] = struct{}{}
+	}
+	for key := range bp.deleted {
+		skip[key] = struct{}{}
+	}
+	bp.RUnlock()
+
+	for _, node := range nodes {
+		if keep, err := f(node); err != nil {
+			return err
+		} else if !keep {
+			return nil
+		}
+	}
+
+	return bp.np.Traverse(func(node Node) (bool, error) {
+		if _, found := skip[string(node.Key())]; found {
+			return true, nil
+		}
+
+		return f(node)
+	})
+}
+
+// Pending returns the number of buffered changes.
+func (bp *BufferedNodePool) Pending() int {
+	bp.RLock()
+	defer bp.RUnlock()
+
+	return len(bp.keys) + len(bp.deleted)
+}
+
+// Flush applies the buffered changes to the inner NodePool at once. If Flush()
+// fails, the buffer is kept.
+func (bp *BufferedNodePool) Flush() error {
+	bp.Lock()
+	defer bp.Unlock()
+
+	return bp.flush()
+}
+
+func (bp *BufferedNodePool) flush() error {
+	if len(bp.keys)+len(bp.deleted) < 1 {
+		return nil
+	}
+
+	nodes := make([]Node, len(bp.keys))
+	for i, key := range bp.keys {
+		nodes[i] = bp.nodes[key]
+	}
+
+	deleted := make([][]byte, 0, len(bp.deleted))
+	for key := range bp.deleted {
+		deleted = append(deleted, []byte(key))
+	}
+
+	if err := ApplyNodes(bp.np, nodes, deleted); err != nil {
+		return err
+	}
+
+	bp.keys = nil
+	bp.nodes = map[string]Node{}
+	bp.deleted = map[string]struct{}{}
+
+	return nil
+}
+
+// Commit flushes the buffer and, if the inner NodePool is
+// CommittableNodePool, commits the inner NodePool.
+func (bp *BufferedNodePool) Commit() error {
+	bp.Lock()
+	defer bp.Unlock()
+
+	if err := bp.flush(); err != nil {
+		return err
+	}
+
+	if cp, ok := bp.np.(CommittableNodePool); ok {
+		return cp.Commit()
+	}
+
+	return nil
+}
+
+// Discard drops the all buffered changes.
+func (bp *BufferedNodePool) Discard() {
+	bp.Lock()
+	defer bp.Unlock()
+
+	bp.keys = nil
+	bp.nodes = map[string]Node{}
+	bp.deleted = map[string]struct{}{}
+}
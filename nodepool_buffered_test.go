@@ -0,0 +1,265 @@
+package avl
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/xerrors"
+)
+
+// testAtomicNodePool counts the Apply() calls.
+type testAtomicNodePool struct {
+	*MapNodePool
+	applies int
+	err     error
+}
+
+func (ap *testAtomicNodePool) Apply(nodes []Node, deleted [][]byte) error {
+	if ap.err != nil {
+		return ap.err
+	}
+
+	ap.applies++
+
+	return ap.MapNodePool.Apply(nodes, deleted)
+}
+
+type testBufferedNodePool struct {
+	suite.Suite
+}
+
+func (t *testBufferedNodePool) TestFlush() {
+	inner := &testAtomicNodePool{MapNodePool: NewMapNodePool(nil)}
+	t.NoError(inner.MapNodePool.Set(newExampleNode(0)))
+
+	bp := NewBufferedNodePool(inner)
+	for i := 1; i < 5; i++ {
+		t.NoError(bp.Set(newExampleNode(i)))
+	}
+	t.Equal(4, bp.Pending())
+
+	// NOTE not written yet
+	n, _ := inner.Len()
+	t.Equal(1, n)
+
+	node, err := bp.Get(nodeIntKey(3))
+	t.NoError(err)
+	t.Equal(nodeIntKey(3), node.Key())
+
+	node, err = bp.Get(nodeIntKey(0))
+	t.NoError(err)
+	t.Equal(nodeIntKey(0), node.Key())
+
+	n, err = bp.Len()
+	t.NoError(err)
+	t.Equal(5, n)
+
+	t.NoError(bp.Flush())
+	t.Equal(0, bp.Pending())
+	t.Equal(1, inner.applies)
+
+	n, _ = inner.Len()
+	t.Equal(5, n)
+}
+
+func (t *testBufferedNodePool) TestFlushFailed() {
+	inner := &testAtomicNodePool{MapNodePool: NewMapNodePool(nil), err: xerrors.Errorf("storage error")}
+	t.NoError(inner.MapNodePool.Set(newExampleNode(0)))
+
+	bp := NewBufferedNodePool(inner)
+	t.NoError(bp.Set(newExampleNode(1)))
+	t.NoError(bp.Delete(nodeIntKey(0)))
+
+	t.Error(bp.Flush())
+	t.Equal(2, bp.Pending())
+
+	inner.err = nil
+	t.NoError(bp.Flush())
+	t.Equal(0, bp.Pending())
+
+	has, err := inner.Has(nodeIntKey(1))
+	t.NoError(err)
+	t.True(has)
+
+	has, err = inner.Has(nodeIntKey(0))
+	t.NoError(err)
+	t.False(has)
+}
+
+func (t *testBufferedNodePool) TestNotAtomic() {
+	inner := NewMapNodePool(nil)
+	t.NoError(inner.Set(newExampleNode(0)))
+
+	for _, np := range []NodePool{
+		struct{ DeletableNodePool }{inner},
+		NewChecksumNodePool(struct{ DeletableNodePool }{inner}, NewBinaryNodeCodec(nil), ChecksumCRC32C),
+	} {
+		bp := NewBufferedNodePool(np)
+		t.NoError(bp.Set(newExampleNode(1)))
+		t.NoError(bp.Delete(nodeIntKey(0)))
+
+		err := bp.Flush()
+		t.True(xerrors.Is(err, NotAtomicNodePoolError), "%T", np)
+		t.Equal(2, bp.Pending())
+
+		n, _ := inner.Len()
+		t.Equal(1, n)
+	}
+}
+
+func (t *testBufferedNodePool) TestDiscard() {
+	inner := NewMapNodePool(nil)
+	t.NoError(inner.Set(newExampleNode(0)))
+
+	bp := NewBufferedNodePool(inner)
+	t.NoError(bp.Set(newExampleNode(1)))
+	t.NoError(bp.Delete(nodeIntKey(0)))
+
+	node, err := bp.Get(nodeIntKey(0))
+	t.NoError(err)
+	t.Nil(node)
+
+	bp.Discard()
+	t.Equal(0, bp.Pending())
+
+	node, err = bp.Get(nodeIntKey(0))
+	t.NoError(err)
+	t.NotNil(node)
+
+	node, err = bp.Get(nodeIntKey(1))
+	t.NoError(err)
+	t.Nil(node)
+}
+
+func (t *testBufferedNodePool) TestDelete() {
+	inner := NewMapNodePool(nil)
+	t.NoError(inner.SetMany([]Node{newExampleNode(0), newExampleNode(1)}))
+
+	bp := NewBufferedNodePool(inner)
+	t.NoError(bp.Set(newExampleNode(2)))
+	t.NoError(bp.Delete(nodeIntKey(2)))
+	t.NoError(bp.Delete(nodeIntKey(0)))
+
+	nodes, err := bp.GetMany([][]byte{nodeIntKey(0), nodeIntKey(1), nodeIntKey(2)})
+	t.NoError(err)
+	t.Nil(nodes[0])
+	t.NotNil(nodes[1])
+	t.Nil(nodes[2])
+
+	n, err := bp.Len()
+	t.NoError(err)
+	t.Equal(1, n)
+
+	var keys []int
+	t.NoError(bp.Traverse(func(node Node) (bool, error) {
+		keys = append(keys, parseNodeIntKey(node.Key()))
+		return true, nil
+	}))
+	t.Equal([]int{1}, keys)
+
+	t.NoError(bp.Flush())
+
+	n, _ = inner.Len()
+	t.Equal(1, n)
+
+	err = NewBufferedNodePool(struct{ NodePool }{inner}).Delete(nodeIntKey(1))
+	t.True(xerrors.Is(err, NotDeletableNodePoolError))
+}
+
+func (t *testBufferedNodePool) TestTraverse() {
+	inner := NewMapNodePool(nil)
+	t.NoError(inner.SetMany([]Node{newExampleNode(0), newExampleNode(1)}))
+
+	bp := NewBufferedNodePool(inner)
+
+	updated := newExampleNode(1)
+	updated.height = 3
+	t.NoError(bp.SetMany([]Node{updated, newExampleNode(2)}))
+
+	var keys []int
+	t.NoError(bp.Traverse(func(node Node) (bool, error) {
+		keys = append(keys, parseNodeIntKey(node.Key()))
+		if parseNodeIntKey(node.Key()) == 1 {
+			t.Equal(int16(3), node.Height())
+		}
+
+		return true, nil
+	}))
+	sort.Ints(keys)
+	t.Equal([]int{0, 1, 2}, keys)
+}
+
+func (t *testBufferedNodePool) TestCommit() {
+	dir, err := ioutil.TempDir("", "avl-buffered-nodepool")
+	t.NoError(err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "nodes")
+
+	fp, err := OpenFileNodePool(path, NewBinaryNodeCodec(nil))
+	t.NoError(err)
+
+	bp := NewBufferedNodePool(fp)
+	btr, err := newExampleTreeInPool(bp, 20)
+	t.NoError(err)
+
+	t.NoError(bp.Commit())
+
+	// NOTE committed nodes survive without Close
+	fp, err = OpenFileNodePool(path, NewBinaryNodeCodec(nil))
+	t.NoError(err)
+	defer fp.Close()
+
+	tr, err := NewTree(btr.Root().Key(), fp)
+	t.NoError(err)
+	t.NoError(tr.IsValid())
+}
+
+func (t *testBufferedNodePool) TestStackedFlush() {
+	inner := &testAtomicNodePool{MapNodePool: NewMapNodePool(nil)}
+
+	// NOTE checksum over namespace over cache
+	np := NewChecksumNodePool(
+		NewNamespacedNodePool(NewCachedNodePool(inner, 10), []byte("a")),
+		NewBinaryNodeCodec(nil),
+		ChecksumCRC32C,
+	)
+	t.NoError(np.Set(newExampleNode(0)))
+
+	bp := NewBufferedNodePool(np)
+	for i := 1; i < 4; i++ {
+		t.NoError(bp.Set(newExampleNode(i)))
+	}
+	t.NoError(bp.Delete(nodeIntKey(0)))
+
+	t.NoError(bp.Flush())
+	t.Equal(1, inner.applies)
+	t.Equal(0, bp.Pending())
+
+	n, err := inner.Len()
+	t.NoError(err)
+	t.Equal(3, n)
+
+	for i := 0; i < 4; i++ {
+		node, err := np.Get(nodeIntKey(i))
+		t.NoError(err)
+
+		if i == 0 {
+			t.Nil(node)
+		} else {
+			t.Equal(nodeIntKey(i), node.Key())
+		}
+	}
+
+	// NOTE nothing to flush
+	t.NoError(bp.Flush())
+	t.Equal(1, inner.applies)
+}
+
+func TestBufferedNodePool(t *testing.T) {
+	suite.Run(t, new(testBufferedNodePool))
+}
@@ -0,0 +1,436 @@
+package avl
+
+import (
+	"container/list"
+	"sync"
+)
+
+type cachedNodePoolItem struct {
+	key  string
+	node Node
+}
+
+// CachedNodePool caches the nodes of inner NodePool with LRU. The nodes of
+// the upper levels of tree can be pinned by Pin(); the pinned nodes are not
+// evicted and are not counted in the capacity.
+//
+// The nodes are cached by the key of Get(), so the content-addressed NodePool
+// like hashable.HashNodePool also can be cached. Set() writes node to the
+// inner NodePool first and then invalidates the cached node of same key; the
+// pinned key stays pinned and the node is loaded again by the next Get(). The
+// node, which is not found, is not cached.
+//
+// The node read from the inner NodePool is not cached if the same key was
+// written while it's read, so the old node can not be cached over the new one.
+type CachedNodePool struct {
+	sync.Mutex
+	np       NodePool
+	capacity int
+	ll       *list.List
+	items    map[string]*list.Element
+	pinned   map[string]Node // NOTE nil node is pinned, but not loaded yet
+	hits     uint64
+	misses   uint64
+	gen      uint64            // increased at every write
+	fills    int               // number of reads from the inner NodePool in progress
+	written  map[string]uint64 // gen of the keys written while fills > 0
+}
+
+func NewCachedNodePool(np NodePool, capacity int) *CachedNodePool {
+	return &CachedNodePool{
+		np:       np,
+		capacity: capacity,
+		ll:       list.New(),
+		items:    map[string]*list.Element{},
+		pinned:   map[string]Node{},
+		written:  map[string]uint64{},
+	}
+}
+
+// NodePool returns the inner NodePool.
+func (cp *CachedNodePool) NodePool() NodePool {
+	return cp.np
+}
+
+func (cp *CachedNodePool) Get(key []byte) (Node, error) {
+	cp.Lock()
+	node, found := cp.load(key)
+	if found {
+		cp.Unlock()
+
+		return node, nil
+	}
+	start := cp.beginFill()
+	cp.Unlock()
+
+	node, err := cp.np.Get(key)
+
+	cp.Lock()
+	defer cp.Unlock()
+	defer cp.endFill()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if node != nil {
+		cp.fill(key, node, start)
+	}
+
+	return node, nil
+}
+
+func (cp *CachedNodePool) GetMany(keys [][]byte) ([]Node, error) {
+	nodes := make([]Node, len(keys))
+
+	var missing [][]byte
+	var indices []int
+
+	cp.Lock()
+	for i, key := range keys {
+		if key == nil {
+			continue
+		}
+
+		if node, found := cp.load(key); found {
+			nodes[i] = node
+			continue
+		}
+
+		missing = append(missing, key)
+		indices = append(indices, i)
+	}
+
+	if len(missing) < 1 {
+		cp.Unlock()
+
+		return nodes, nil
+	}
+	start := cp.beginFill()
+	cp.Unlock()
+
+	found, err := GetNodes(cp.np, missing)
+
+	cp.Lock()
+	defer cp.Unlock()
+	defer cp.endFill()
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i, node := range found {
+		if node == nil {
+			continue
+		}
+
+		nodes[indices[i]] = node
+		cp.fill(missing[i], node, start)
+	}
+
+	return nodes, nil
+}
+
+// beginFill starts reading from the inner NodePool and returns the current
+// gen.
+func (cp *CachedNodePool) beginFill() uint64 {
+	cp.fills++
+
+	return cp.gen
+}
+
+func (cp *CachedNodePool) endFill() {
+	cp.fills--
+
+	if cp.fills < 1 && len(cp.written) > 0 {
+		cp.written = map[string]uint64{}
+	}
+}
+
+// isWritten checks the key was written after start.
+func (cp *CachedNodePool) isWritten(key []byte, start uint64) bool {
+	gen, found := cp.written[string(key)]
+
+	return found && gen > start
+}
+
+// fill caches the node read from the inner NodePool, if the key was not
+// written after start.
+func (cp *CachedNodePool) fill(key []byte, node Node, start uint64) {
+	if cp.isWritten(key, start) {
+		return
+	}
+
+	cp.store(key, node)
+}
+
+// touch marks key is written.
+func (cp *CachedNodePool) touch(key []byte) {
+	cp.gen++
+
+	if cp.fills > 0 {
+		cp.written[string(key)] = cp.gen
+	}
+}
+
+// load returns the cached node and counts hit and miss.
+func (cp *CachedNodePool) load(key []byte) (Node, bool) {
+	if node, found := cp.pinned[string(key)]; found && node != nil {
+		cp.hits++
+		return node, true
+	}
+
+	if e, found := cp.items[string(key)]; found {
+		cp.hits++
+		cp.ll.MoveToFront(e)
+
+		return e.Value.(cachedNodePoolItem).node, true
+	}
+
+	cp.misses++
+
+	return nil, false
+}
+
+// store caches node by key. If key is pinned, the pinned node is replaced.
+func (cp *CachedNodePool) store(k []byte, node Node) {
+	key := string(k)
+
+	if _, found := cp.pinned[key]; found {
+		cp.pinned[key] = node
+		return
+	}
+
+	if e, found := cp.items[key]; found {
+		e.Value = cachedNodePoolItem{key: key, node: node}
+		cp.ll.MoveToFront(e)
+
+		return
+	}
+
+	if cp.capacity < 1 {
+		return
+	}
+
+	cp.items[key] = cp.ll.PushFront(cachedNodePoolItem{key: key, node: node})
+
+	for cp.ll.Len() > cp.capacity {
+		cp.evict(cp.ll.Back())
+	}
+}
+
+// invalidate removes the cached node of key. The pinned key stays pinned
+// without node.
+func (cp *CachedNodePool) invalidate(key []byte) {
+	cp.touch(key)
+
+	if _, found := cp.pinned[string(key)]; found {
+		cp.pinned[string(key)] = nil
+	}
+
+	if e, found := cp.items[string(key)]; found {
+		cp.evict(e)
+	}
+}
+
+func (cp *CachedNodePool) evict(e *list.Element) {
+	cp.ll.Remove(e)
+	delete(cp.items, e.Value.(cachedNodePoolItem).key)
+}
+
+func (cp *CachedNodePool) forget(key []byte) {
+	cp.touch(key)
+
+	delete(cp.pinned, string(key))
+
+	if e, found := cp.items[string(key)]; found {
+		cp.evict(e)
+	}
+}
+
+// Set writes node to the inner NodePool and invalidates the cached node.
+func (cp *CachedNodePool) Set(node Node) error {
+	if err := cp.np.Set(node); err != nil {
+		return err
+	}
+
+	cp.Lock()
+	cp.invalidate(node.Key())
+	cp.Unlock()
+
+	return nil
+}
+
+func (cp *CachedNodePool) SetMany(nodes []Node) error {
+	if err := SetNodes(cp.np, nodes); err != nil {
+		return err
+	}
+
+	cp.Lock()
+	defer cp.Unlock()
+
+	for _, node := range nodes {
+		cp.invalidate(node.Key())
+	}
+
+	return nil
+}
+
+// Apply applies the changes by the inner NodePool and invalidates the cached
+// nodes. The inner NodePool should implement AtomicNodePool.
+func (cp *CachedNodePool) Apply(nodes []Node, deleted [][]byte) error {
+	if err := ApplyNodes(cp.np, nodes, deleted); err != nil {
+		return err
+	}
+
+	cp.Lock()
+	defer cp.Unlock()
+
+	for _, node := range nodes {
+		cp.invalidate(node.Key())
+	}
+
+	for _, key := range deleted {
+		cp.forget(key)
+	}
+
+	return nil
+}
+
+// Delete removes node from the inner NodePool and the cache. The inner
+// NodePool should implement DeletableNodePool.
+func (cp *CachedNodePool) Delete(key []byte) error {
+	dp, ok := cp.np.(DeletableNodePool)
+	if !ok {
+		return NotDeletableNodePoolError.Wrapf("type=%T", cp.np)
+	}
+
+	if err := dp.Delete(key); err != nil {
+		return err
+	}
+
+	cp.Lock()
+	cp.forget(key)
+	cp.Unlock()
+
+	return nil
+}
+
+func (cp *CachedNodePool) Has(key []byte) (bool, error) {
+	cp.Lock()
+	_, pinned := cp.pinned[string(key)]
+	_, cached := cp.items[string(key)]
+	cp.Unlock()
+
+	if pinned || cached {
+		return true, nil
+	}
+
+	return HasNode(cp.np, key)
+}
+
+func (cp *CachedNodePool) Len() (int, error) {
+	return CountNodes(cp.np)
+}
+
+// Traverse traverses the inner NodePool. The traversed nodes are not cached.
+func (cp *CachedNodePool) Traverse(f NodeTraverseFunc) error {
+	return cp.np.Traverse(f)
+}
+
+// Pin loads and pins the nodes from root to the given levels; if levels is 1,
+// only root is pinned. The previously pinned nodes are unpinned.
+func (cp *CachedNodePool) Pin(rootKey []byte, levels int) error {
+	cp.Lock()
+	start := cp.beginFill()
+	cp.Unlock()
+
+	defer func() {
+		cp.Lock()
+		cp.endFill()
+		cp.Unlock()
+	}()
+
+	pinned := map[string]Node{}
+
+	keys := [][]byte{rootKey}
+	for level := 0; level < levels && len(keys) > 0; level++ {
+		nodes, err := GetNodes(cp.np, keys)
+		if err != nil {
+			return err
+		}
+
+		current := keys
+		keys = nil
+		for i, node := range nodes {
+			if node == nil {
+				if level == 0 {
+					return NodeNotFoundInPoolError.Wrapf("root key=%x", rootKey)
+				}
+
+				return NodeNotFoundInPoolError.Wrapf("key=%x", current[i])
+			} else if _, found := pinned[string(current[i])]; found {
+				continue
+			}
+
+			pinned[string(current[i])] = node
+
+			for _, key := range [][]byte{node.LeftKey(), node.RightKey()} {
+				if key != nil {
+					keys = append(keys, key)
+				}
+			}
+		}
+	}
+
+	cp.Lock()
+	defer cp.Unlock()
+
+	for key := range pinned {
+		if cp.isWritten([]byte(key), start) {
+			pinned[key] = nil
+		}
+
+		if e, found := cp.items[key]; found {
+			cp.evict(e)
+		}
+	}
+
+	cp.pinned = pinned
+
+	return nil
+}
+
+// Unpin unpins the all pinned nodes.
+func (cp *CachedNodePool) Unpin() {
+	cp.Lock()
+	defer cp.Unlock()
+
+	cp.pinned = map[string]Node{}
+}
+
+// Purge removes the all cached and pinned nodes. The counters are not reset.
+func (cp *CachedNodePool) Purge() {
+	cp.Lock()
+	defer cp.Unlock()
+
+	cp.ll.Init()
+	cp.items = map[string]*list.Element{}
+	cp.pinned = map[string]Node{}
+}
+
+// Hits returns the number of the nodes found in cache.
+func (cp *CachedNodePool) Hits() uint64 {
+	cp.Lock()
+	defer cp.Unlock()
+
+	return cp.hits
+}
+
+// Misses returns the number of the nodes not found in cache.
+func (cp *CachedNodePool) Misses() uint64 {
+	cp.Lock()
+	defer cp.Unlock()
+
+	return cp.misses
+}
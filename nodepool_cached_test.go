@@ -0,0 +1,238 @@
+package avl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/xerrors"
+)
+
+// testCountingNodePool counts the Get() calls of inner NodePool. If afterGet
+// is not nil, it's called after the node is read.
+type testCountingNodePool struct {
+	NodePool
+	gets     int
+	afterGet func([]byte)
+}
+
+func newTestCountingNodePool() *testCountingNodePool {
+	return &testCountingNodePool{NodePool: NewMapNodePool(nil)}
+}
+
+func (cp *testCountingNodePool) Get(key []byte) (Node, error) {
+	cp.gets++
+
+	node, err := cp.NodePool.Get(key)
+	if cp.afterGet != nil {
+		cp.afterGet(key)
+	}
+
+	return node, err
+}
+
+func (cp *testCountingNodePool) Delete(key []byte) error {
+	return cp.NodePool.(DeletableNodePool).Delete(key)
+}
+
+type testCachedNodePool struct {
+	suite.Suite
+}
+
+// newTree stores the tree of n nodes into the inner NodePool of
+// testCountingNodePool, so the Get() calls are not counted.
+func (t *testCachedNodePool) newTree(n int) (*Tree, *testCountingNodePool) {
+	np := newTestCountingNodePool()
+
+	tr, err := newExampleTreeInPool(np.NodePool, n)
+	t.NoError(err)
+
+	return tr, np
+}
+
+func (t *testCachedNodePool) TestLRU() {
+	inner := newTestCountingNodePool()
+	for i := 0; i < 5; i++ {
+		t.NoError(inner.NodePool.Set(newExampleNode(i)))
+	}
+
+	cp := NewCachedNodePool(inner, 2)
+
+	for _, i := range []int{0, 1, 0, 2, 1, 0} {
+		node, err := cp.Get(nodeIntKey(i))
+		t.NoError(err)
+		t.Equal(nodeIntKey(i), node.Key())
+	}
+
+	// NOTE 0, 1, hit 0, 2 evicts 1, 1 evicts 0, 0 evicts 2
+	t.Equal(uint64(1), cp.Hits())
+	t.Equal(uint64(5), cp.Misses())
+	t.Equal(5, inner.gets)
+
+	// NOTE unknown node is not cached
+	for i := 0; i < 2; i++ {
+		node, err := cp.Get(nodeIntKey(10))
+		t.NoError(err)
+		t.Nil(node)
+	}
+	t.Equal(7, inner.gets)
+}
+
+func (t *testCachedNodePool) TestGetMany() {
+	inner := newTestCountingNodePool()
+	for i := 0; i < 5; i++ {
+		t.NoError(inner.NodePool.Set(newExampleNode(i)))
+	}
+
+	cp := NewCachedNodePool(inner, 10)
+
+	_, err := cp.Get(nodeIntKey(1))
+	t.NoError(err)
+
+	nodes, err := cp.GetMany([][]byte{nodeIntKey(1), nil, nodeIntKey(2), nodeIntKey(10)})
+	t.NoError(err)
+	t.Equal(nodeIntKey(1), nodes[0].Key())
+	t.Nil(nodes[1])
+	t.Equal(nodeIntKey(2), nodes[2].Key())
+	t.Nil(nodes[3])
+
+	t.Equal(uint64(1), cp.Hits())
+	t.Equal(uint64(3), cp.Misses())
+}
+
+func (t *testCachedNodePool) TestPin() {
+	tr, inner := t.newTree(31)
+
+	cp := NewCachedNodePool(inner, 0)
+	t.NoError(cp.Pin(tr.Root().Key(), 3))
+	t.Equal(7, inner.gets)
+
+	ctr, err := NewTree(tr.Root().Key(), cp)
+	t.NoError(err)
+	inner.gets = 0
+
+	// NOTE root is pinned, so NewTree does not fetch it
+	_, err = ctr.Get(nodeIntKey(0))
+	t.NoError(err)
+
+	depth := int(tr.Root().Height())
+	t.Equal(depth+1-3, inner.gets)
+
+	cp.Unpin()
+	inner.gets = 0
+
+	_, err = ctr.Get(nodeIntKey(0))
+	t.NoError(err)
+	t.Equal(depth, inner.gets)
+
+	err = cp.Pin(nodeIntKey(100), 2)
+	t.True(xerrors.Is(err, NodeNotFoundInPoolError))
+}
+
+func (t *testCachedNodePool) TestSet() {
+	inner := NewMapNodePool(nil)
+	cp := NewCachedNodePool(inner, 10)
+
+	t.NoError(cp.Set(newExampleNode(1)))
+
+	// NOTE write through
+	node, err := inner.Get(nodeIntKey(1))
+	t.NoError(err)
+	t.NotNil(node)
+
+	_, err = cp.Get(nodeIntKey(1))
+	t.NoError(err)
+
+	updated := newExampleNode(1)
+	updated.height = 3
+	t.NoError(cp.Set(updated))
+
+	node, err = cp.Get(nodeIntKey(1))
+	t.NoError(err)
+	t.Equal(int16(3), node.Height())
+
+	t.NoError(cp.Delete(nodeIntKey(1)))
+
+	node, err = cp.Get(nodeIntKey(1))
+	t.NoError(err)
+	t.Nil(node)
+}
+
+func (t *testCachedNodePool) TestStaleFill() {
+	inner := newTestCountingNodePool()
+	t.NoError(inner.NodePool.Set(newExampleNode(1)))
+
+	cp := NewCachedNodePool(inner, 10)
+
+	updated := newExampleNode(1)
+	updated.height = 3
+
+	// NOTE the old node is read and then the new node is set before it's
+	// cached.
+	inner.afterGet = func([]byte) {
+		inner.afterGet = nil
+		t.NoError(cp.Set(updated))
+	}
+
+	node, err := cp.Get(nodeIntKey(1))
+	t.NoError(err)
+	t.Equal(int16(0), node.Height())
+
+	node, err = cp.Get(nodeIntKey(1))
+	t.NoError(err)
+	t.Equal(int16(3), node.Height())
+
+	// NOTE removed before it's cached
+	inner.afterGet = func([]byte) {
+		inner.afterGet = nil
+		t.NoError(cp.Delete(nodeIntKey(1)))
+	}
+
+	cp.Purge()
+	_, err = cp.GetMany([][]byte{nodeIntKey(1)})
+	t.NoError(err)
+
+	node, err = cp.Get(nodeIntKey(1))
+	t.NoError(err)
+	t.Nil(node)
+}
+
+func (t *testCachedNodePool) TestStalePin() {
+	tr, inner := t.newTree(7)
+	rootKey := tr.Root().Key()
+
+	root, err := inner.NodePool.Get(rootKey)
+	t.NoError(err)
+	updated := *root.(*ExampleMutableNode)
+	t.NoError(updated.SetHeight(9))
+
+	cp := NewCachedNodePool(inner, 10)
+
+	inner.afterGet = func([]byte) {
+		inner.afterGet = nil
+		t.NoError(cp.Set(&updated))
+	}
+
+	t.NoError(cp.Pin(rootKey, 2))
+
+	node, err := cp.Get(rootKey)
+	t.NoError(err)
+	t.Equal(int16(9), node.Height())
+
+	// NOTE root is still pinned
+	inner.gets = 0
+
+	_, err = cp.Get(rootKey)
+	t.NoError(err)
+	t.Equal(0, inner.gets)
+}
+
+func (t *testCachedNodePool) TestNotDeletable() {
+	cp := NewCachedNodePool(struct{ NodePool }{NewMapNodePool(nil)}, 10)
+
+	err := cp.Delete(nodeIntKey(1))
+	t.True(xerrors.Is(err, NotDeletableNodePoolError))
+}
+
+func TestCachedNodePool(t *testing.T) {
+	suite.Run(t, new(testCachedNodePool))
+}
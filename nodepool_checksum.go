@@ -0,0 +1,123 @@
+package avl
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"hash/crc32"
+)
+
+// ChecksumAlgorithm is the checksum algorithm of ChecksumNodePool.
+type ChecksumAlgorithm byte
+
+const (
+	ChecksumCRC32C ChecksumAlgorithm = iota + 1
+	ChecksumSHA256
+)
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+func (ca ChecksumAlgorithm) String() string {
+	switch ca {
+	case ChecksumCRC32C:
+		return "crc32c"
+	case ChecksumSHA256:
+		return "sha256"
+	default:
+		return "unknown"
+	}
+}
+
+func (ca ChecksumAlgorithm) sum(b []byte) ([]byte, error) {
+	switch ca {
+	case ChecksumCRC32C:
+		s := make([]byte, 4)
+		binary.BigEndian.PutUint32(s, crc32.Checksum(b, crc32cTable))
+
+		return s, nil
+	case ChecksumSHA256:
+		s := sha256.Sum256(b)
+
+		return s[:], nil
+	default:
+		return nil, InvalidEncodedNodeError.Wrapf("unknown checksum algorithm; algorithm=%d", ca)
+	}
+}
+
+func (ca ChecksumAlgorithm) size() int {
+	switch ca {
+	case ChecksumCRC32C:
+		return 4
+	case ChecksumSHA256:
+		return sha256.Size
+	default:
+		return -1
+	}
+}
+
+// ChecksumNodePool stores the checksum with the encoded node and checks it at
+// every Get() and Traverse(). The stored payload is,
+//
+//	algorithm(1 byte) | checksum | encoded node
+//
+// The algorithm is kept in the payload, so the node stored by the different
+// algorithm still can be verified. If the checksum does not match, Get()
+// returns CorruptedNodeError with NodeCorruptionError, which has the key of
+// node.
+type ChecksumNodePool struct {
+	*codecNodePool
+	algorithm ChecksumAlgorithm
+}
+
+func NewChecksumNodePool(np NodePool, codec NodeCodec, algorithm ChecksumAlgorithm) *ChecksumNodePool {
+	cp := &ChecksumNodePool{algorithm: algorithm}
+	cp.codecNodePool = &codecNodePool{
+		np:     np,
+		codec:  codec,
+		encode: cp.encode,
+		decode: cp.decode,
+	}
+
+	return cp
+}
+
+func (cp *ChecksumNodePool) encode(_, b []byte) ([]byte, error) {
+	s, err := cp.algorithm.sum(b)
+	if err != nil {
+		return nil, err
+	}
+
+	p := make([]byte, 0, 1+len(s)+len(b))
+	p = append(p, byte(cp.algorithm))
+	p = append(p, s...)
+
+	return append(p, b...), nil
+}
+
+func (cp *ChecksumNodePool) decode(_, p []byte) ([]byte, error) {
+	if len(p) < 1 {
+		return nil, InvalidEncodedNodeError.Wrapf("empty payload")
+	}
+
+	algorithm := ChecksumAlgorithm(p[0])
+
+	size := algorithm.size()
+	if size < 0 {
+		return nil, InvalidEncodedNodeError.Wrapf("unknown checksum algorithm; algorithm=%d", p[0])
+	} else if len(p) < 1+size {
+		return nil, InvalidEncodedNodeError.Wrapf("too short payload; length=%d", len(p))
+	}
+
+	b := p[1+size:]
+
+	s, err := algorithm.sum(b)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(s, p[1:1+size]) {
+		return nil, InvalidEncodedNodeError.Wrapf("%s checksum not match", algorithm)
+	}
+
+	return b, nil
+}
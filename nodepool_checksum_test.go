@@ -0,0 +1,113 @@
+package avl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/xerrors"
+)
+
+type testChecksumNodePool struct {
+	suite.Suite
+}
+
+// corrupt flips the last byte of stored payload.
+func (t *testChecksumNodePool) corrupt(inner *MapNodePool, key []byte) {
+	raw, err := inner.Get(key)
+	t.NoError(err)
+
+	bn := raw.(BaseNode)
+	p := append([]byte{}, bn.Payload()...)
+	p[len(p)-1] ^= 0xff
+
+	t.NoError(inner.Set(bn.WithPayload(p)))
+}
+
+func (t *testChecksumNodePool) TestAlgorithms() {
+	for _, algorithm := range []ChecksumAlgorithm{ChecksumCRC32C, ChecksumSHA256} {
+		inner := NewMapNodePool(nil)
+		cp := NewChecksumNodePool(inner, NewBinaryNodeCodec(nil), algorithm)
+
+		tr, err := newExampleTreeInPool(cp, 20)
+		t.NoError(err)
+		t.NoError(tr.IsValid(), algorithm.String())
+
+		raw, err := inner.Get(tr.Root().Key())
+		t.NoError(err)
+		t.Equal(byte(algorithm), raw.(BaseNode).Payload()[0])
+	}
+}
+
+func (t *testChecksumNodePool) TestCorrupted() {
+	inner := NewMapNodePool(nil)
+	cp := NewChecksumNodePool(inner, NewBinaryNodeCodec(nil), ChecksumCRC32C)
+
+	tr, err := newExampleTreeInPool(cp, 20)
+	t.NoError(err)
+
+	t.corrupt(inner, nodeIntKey(3))
+
+	_, err = cp.Get(nodeIntKey(3))
+	t.True(xerrors.Is(err, CorruptedNodeError))
+
+	var ne NodeCorruptionError
+	t.True(xerrors.As(err, &ne))
+	t.Equal(nodeIntKey(3), ne.Key)
+
+	// NOTE not reported as invalid tree
+	err = tr.IsValid()
+	t.True(xerrors.Is(err, CorruptedNodeError))
+	t.False(xerrors.Is(err, InvalidTreeError))
+
+	err = cp.Traverse(func(Node) (bool, error) { return true, nil })
+	t.True(xerrors.Is(err, CorruptedNodeError))
+}
+
+func (t *testChecksumNodePool) TestMixedAlgorithms() {
+	inner := NewMapNodePool(nil)
+
+	t.NoError(NewChecksumNodePool(inner, NewBinaryNodeCodec(nil), ChecksumSHA256).Set(newExampleNode(1)))
+
+	cp := NewChecksumNodePool(inner, NewBinaryNodeCodec(nil), ChecksumCRC32C)
+	t.NoError(cp.Set(newExampleNode(2)))
+
+	nodes, err := cp.GetMany([][]byte{nodeIntKey(1), nodeIntKey(2), nodeIntKey(3)})
+	t.NoError(err)
+	t.Equal(nodeIntKey(1), nodes[0].Key())
+	t.Equal(nodeIntKey(2), nodes[1].Key())
+	t.Nil(nodes[2])
+}
+
+func (t *testChecksumNodePool) TestSwappedNode() {
+	inner := NewMapNodePool(nil)
+	cp := NewChecksumNodePool(inner, NewBinaryNodeCodec(nil), ChecksumCRC32C)
+
+	t.NoError(cp.SetMany([]Node{newExampleNode(1), newExampleNode(2)}))
+
+	// NOTE valid record under the different key
+	raw, err := inner.Get(nodeIntKey(2))
+	t.NoError(err)
+	t.NoError(inner.Set(NewBaseNode(nodeIntKey(1), 0, nil, nil, raw.(BaseNode).Payload())))
+
+	_, err = cp.Get(nodeIntKey(1))
+	t.True(xerrors.Is(err, CorruptedNodeError))
+}
+
+func (t *testChecksumNodePool) TestDelete() {
+	inner := NewMapNodePool(nil)
+	cp := NewChecksumNodePool(inner, NewBinaryNodeCodec(nil), ChecksumCRC32C)
+
+	t.NoError(cp.Set(newExampleNode(1)))
+	t.NoError(cp.Delete(nodeIntKey(1)))
+
+	has, err := cp.Has(nodeIntKey(1))
+	t.NoError(err)
+	t.False(has)
+
+	err = NewChecksumNodePool(struct{ NodePool }{inner}, NewBinaryNodeCodec(nil), ChecksumCRC32C).Delete(nodeIntKey(1))
+	t.True(xerrors.Is(err, NotDeletableNodePoolError))
+}
+
+func TestChecksumNodePool(t *testing.T) {
+	suite.Run(t, new(testChecksumNodePool))
+}
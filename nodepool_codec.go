@@ -0,0 +1,244 @@
+package avl
+
+import (
+	"fmt"
+)
+
+var (
+	CorruptedNodeError = NewWrapError("corrupted node")
+)
+
+// NodeCorruptionError is the error of the corrupted node in NodePool. Key is
+// the key of node.
+type NodeCorruptionError struct {
+	Key []byte
+	Err error
+}
+
+func (ne NodeCorruptionError) Error() string {
+	return fmt.Sprintf("key=%x: %v", ne.Key, ne.Err)
+}
+
+func (ne NodeCorruptionError) Unwrap() error {
+	return ne.Err
+}
+
+func newCorruptedNodeError(key []byte, err error) error {
+	return CorruptedNodeError.Wrap(NodeCorruptionError{Key: key, Err: err})
+}
+
+// codecNodePool is the base of the NodePool decorators, which transform the
+// encoded node. The node is encoded by NodeCodec and transformed by encode,
+// and then it's stored in the inner NodePool as the payload of BaseNode. The
+// inner NodePool should keep the payload of BaseNode; if it's the file based
+// NodePool, BinaryNodeCodec without PayloadCodec keeps the payload, so the
+// decorators can be stacked.
+type codecNodePool struct {
+	np     NodePool
+	codec  NodeCodec
+	key    func(key []byte) ([]byte, error)    // key in the inner NodePool; if nil, the key of node is used
+	encode func(key, b []byte) ([]byte, error) // key is the key in the inner NodePool
+	decode func(key, b []byte) ([]byte, error)
+}
+
+// NodePool returns the inner NodePool.
+func (cp *codecNodePool) NodePool() NodePool {
+	return cp.np
+}
+
+func (cp *codecNodePool) storageKey(key []byte) ([]byte, error) {
+	if cp.key == nil {
+		return key, nil
+	}
+
+	return cp.key(key)
+}
+
+func (cp *codecNodePool) Get(key []byte) (Node, error) {
+	sk, err := cp.storageKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := cp.np.Get(sk)
+	if err != nil || raw == nil {
+		return nil, err
+	}
+
+	return cp.decodeNode(key, raw)
+}
+
+// decodeNode decodes the node from the inner NodePool. If key is not nil,
+// the key of decoded node should be same with key.
+func (cp *codecNodePool) decodeNode(key []byte, raw Node) (Node, error) {
+	ek := key
+	if ek == nil {
+		ek = raw.Key()
+	}
+
+	bn, ok := raw.(BaseNode)
+	if !ok {
+		return nil, newCorruptedNodeError(ek, InvalidNodeError.Wrapf("not BaseNode; type=%T", raw))
+	}
+
+	b, err := cp.decode(raw.Key(), bn.Payload())
+	if err != nil {
+		return nil, newCorruptedNodeError(ek, err)
+	}
+
+	node, err := cp.codec.Decode(b)
+	if err != nil {
+		return nil, newCorruptedNodeError(ek, err)
+	}
+
+	if key != nil && !EqualKey(key, node.Key()) {
+		return nil, newCorruptedNodeError(ek, InvalidNodeError.Wrapf("key not match; decoded=%x", node.Key()))
+	}
+
+	return node, nil
+}
+
+func (cp *codecNodePool) GetMany(keys [][]byte) ([]Node, error) {
+	sks := make([][]byte, len(keys))
+	for i, key := range keys {
+		if key == nil {
+			continue
+		}
+
+		sk, err := cp.storageKey(key)
+		if err != nil {
+			return nil, err
+		}
+		sks[i] = sk
+	}
+
+	raws, err := GetNodes(cp.np, sks)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make([]Node, len(keys))
+	for i, raw := range raws {
+		if raw == nil {
+			continue
+		}
+
+		node, err := cp.decodeNode(keys[i], raw)
+		if err != nil {
+			return nil, err
+		}
+		nodes[i] = node
+	}
+
+	return nodes, nil
+}
+
+func (cp *codecNodePool) encodeNode(node Node) (Node, error) {
+	sk, err := cp.storageKey(node.Key())
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := cp.codec.Encode(node)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := cp.encode(sk, b)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewBaseNode(sk, 0, nil, nil, payload), nil
+}
+
+func (cp *codecNodePool) Set(node Node) error {
+	raw, err := cp.encodeNode(node)
+	if err != nil {
+		return err
+	}
+
+	return cp.np.Set(raw)
+}
+
+func (cp *codecNodePool) SetMany(nodes []Node) error {
+	raws := make([]Node, len(nodes))
+	for i := range nodes {
+		raw, err := cp.encodeNode(nodes[i])
+		if err != nil {
+			return err
+		}
+		raws[i] = raw
+	}
+
+	return SetNodes(cp.np, raws)
+}
+
+// Apply encodes the nodes and applies the changes by the inner NodePool. The
+// inner NodePool should implement AtomicNodePool.
+func (cp *codecNodePool) Apply(nodes []Node, deleted [][]byte) error {
+	if _, ok := cp.np.(AtomicNodePool); !ok {
+		return NotAtomicNodePoolError.Wrapf("type=%T", cp.np)
+	}
+
+	raws := make([]Node, len(nodes))
+	for i := range nodes {
+		raw, err := cp.encodeNode(nodes[i])
+		if err != nil {
+			return err
+		}
+		raws[i] = raw
+	}
+
+	sks := make([][]byte, len(deleted))
+	for i := range deleted {
+		sk, err := cp.storageKey(deleted[i])
+		if err != nil {
+			return err
+		}
+		sks[i] = sk
+	}
+
+	return ApplyNodes(cp.np, raws, sks)
+}
+
+// Delete removes node from the inner NodePool. The inner NodePool should
+// implement DeletableNodePool.
+func (cp *codecNodePool) Delete(key []byte) error {
+	dp, ok := cp.np.(DeletableNodePool)
+	if !ok {
+		return NotDeletableNodePoolError.Wrapf("type=%T", cp.np)
+	}
+
+	sk, err := cp.storageKey(key)
+	if err != nil {
+		return err
+	}
+
+	return dp.Delete(sk)
+}
+
+func (cp *codecNodePool) Has(key []byte) (bool, error) {
+	sk, err := cp.storageKey(key)
+	if err != nil {
+		return false, err
+	}
+
+	return HasNode(cp.np, sk)
+}
+
+func (cp *codecNodePool) Len() (int, error) {
+	return CountNodes(cp.np)
+}
+
+// Traverse traverses the decoded nodes of inner NodePool.
+func (cp *codecNodePool) Traverse(f NodeTraverseFunc) error {
+	return cp.np.Traverse(func(raw Node) (bool, error) {
+		node, err := cp.decodeNode(nil, raw)
+		if err != nil {
+			return false, err
+		}
+
+		return f(node)
+	})
+}
@@ -0,0 +1,173 @@
+package avl
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+)
+
+// CompressionAlgorithm is the compression algorithm of CompressedNodePool.
+type CompressionAlgorithm byte
+
+const (
+	CompressionNone CompressionAlgorithm = iota
+	CompressionFlate
+	CompressionGzip
+)
+
+func (ca CompressionAlgorithm) String() string {
+	switch ca {
+	case CompressionNone:
+		return "none"
+	case CompressionFlate:
+		return "flate"
+	case CompressionGzip:
+		return "gzip"
+	default:
+		return "unknown"
+	}
+}
+
+// maxDecompressedSize is the limit of the length of decompressed node.
+const maxDecompressedSize = 1 << 28
+
+func (ca CompressionAlgorithm) compress(b []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte(byte(ca))
+
+	var l [binary.MaxVarintLen64]byte
+	buf.Write(l[:binary.PutUvarint(l[:], uint64(len(b)))])
+
+	var w io.WriteCloser
+	switch ca {
+	case CompressionFlate:
+		fw, err := flate.NewWriter(&buf, flate.DefaultCompression)
+		if err != nil {
+			return nil, err
+		}
+		w = fw
+	case CompressionGzip:
+		w = gzip.NewWriter(&buf)
+	default:
+		return nil, InvalidEncodedNodeError.Wrapf("unknown compression algorithm; algorithm=%d", ca)
+	}
+
+	if _, err := w.Write(b); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// decompress decompresses b, which starts with the length of decompressed
+// node. It reads no more than the length, so the crafted record can not be
+// inflated without bound.
+func (ca CompressionAlgorithm) decompress(b []byte) ([]byte, error) {
+	length, n := binary.Uvarint(b)
+	if n <= 0 {
+		return nil, InvalidEncodedNodeError.Wrapf("invalid decompressed length")
+	} else if length > maxDecompressedSize {
+		return nil, InvalidEncodedNodeError.Wrapf("too large decompressed length; length=%d", length)
+	}
+
+	var r io.ReadCloser
+	switch ca {
+	case CompressionFlate:
+		r = flate.NewReader(bytes.NewReader(b[n:]))
+	case CompressionGzip:
+		gr, err := gzip.NewReader(bytes.NewReader(b[n:]))
+		if err != nil {
+			return nil, err
+		}
+		r = gr
+	default:
+		return nil, InvalidEncodedNodeError.Wrapf("unknown compression algorithm; algorithm=%d", ca)
+	}
+	defer r.Close()
+
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(io.LimitReader(r, int64(length)+1)); err != nil {
+		return nil, err
+	}
+
+	if uint64(buf.Len()) != length {
+		return nil, InvalidEncodedNodeError.Wrapf(
+			"decompressed length not match; length=%d, expected=%d", buf.Len(), length)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// CompressedNodePool compresses the encoded node, which is larger than the
+// threshold. The stored payload is,
+//
+//	algorithm(1 byte) | encoded node
+//
+// and if it's compressed, the encoded node is,
+//
+//	decompressed length(uvarint) | compressed node
+//
+// If the node is smaller than the threshold or it's not smaller after
+// compression, the node is stored without compression with CompressionNone,
+// so the compressed and uncompressed nodes can be in the same NodePool.
+type CompressedNodePool struct {
+	*codecNodePool
+	algorithm CompressionAlgorithm
+	threshold int
+}
+
+func NewCompressedNodePool(
+	np NodePool, codec NodeCodec, algorithm CompressionAlgorithm, threshold int,
+) *CompressedNodePool {
+	cp := &CompressedNodePool{algorithm: algorithm, threshold: threshold}
+	cp.codecNodePool = &codecNodePool{
+		np:     np,
+		codec:  codec,
+		encode: cp.encode,
+		decode: cp.decode,
+	}
+
+	return cp
+}
+
+func (cp *CompressedNodePool) encode(_, b []byte) ([]byte, error) {
+	if cp.algorithm != CompressionNone && len(b) >= cp.threshold {
+		c, err := cp.algorithm.compress(b)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(c) < len(b)+1 {
+			return c, nil
+		}
+	}
+
+	p := make([]byte, len(b)+1)
+	p[0] = byte(CompressionNone)
+	copy(p[1:], b)
+
+	return p, nil
+}
+
+func (cp *CompressedNodePool) decode(_, p []byte) ([]byte, error) {
+	if len(p) < 1 {
+		return nil, InvalidEncodedNodeError.Wrapf("empty payload")
+	}
+
+	algorithm := CompressionAlgorithm(p[0])
+	if algorithm == CompressionNone {
+		return p[1:], nil
+	}
+
+	b, err := algorithm.decompress(p[1:])
+	if err != nil {
+		return nil, InvalidEncodedNodeError.Wrapf("failed to decompress; algorithm=%s: %w", algorithm, err)
+	}
+
+	return b, nil
+}
@@ -0,0 +1,146 @@
+package avl
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/xerrors"
+)
+
+type testCompressedNodePool struct {
+	suite.Suite
+}
+
+func (t *testCompressedNodePool) newNode(i, size int) BaseNode {
+	return NewBaseNode(nodeIntKey(i), 0, nil, nil, bytes.Repeat([]byte(`{"a":1}`), size))
+}
+
+func (t *testCompressedNodePool) payload(inner NodePool, key []byte) []byte {
+	raw, err := inner.Get(key)
+	t.NoError(err)
+
+	return raw.(BaseNode).Payload()
+}
+
+func (t *testCompressedNodePool) TestThreshold() {
+	for _, algorithm := range []CompressionAlgorithm{CompressionFlate, CompressionGzip} {
+		inner := NewMapNodePool(nil)
+		cp := NewCompressedNodePool(inner, NewBinaryNodeCodec(nil), algorithm, 100)
+
+		small, large := t.newNode(1, 2), t.newNode(2, 1000)
+		t.NoError(cp.SetMany([]Node{small, large}))
+
+		p := t.payload(inner, small.Key())
+		t.Equal(byte(CompressionNone), p[0], algorithm.String())
+
+		p = t.payload(inner, large.Key())
+		t.Equal(byte(algorithm), p[0], algorithm.String())
+		t.True(len(p) < len(large.Payload()), algorithm.String())
+
+		nodes, err := cp.GetMany([][]byte{small.Key(), large.Key()})
+		t.NoError(err)
+		t.Equal(small, nodes[0])
+		t.Equal(large, nodes[1])
+	}
+}
+
+func (t *testCompressedNodePool) TestMixed() {
+	inner := NewMapNodePool(nil)
+
+	t.NoError(NewCompressedNodePool(inner, NewBinaryNodeCodec(nil), CompressionNone, 0).Set(t.newNode(1, 1000)))
+	t.NoError(NewCompressedNodePool(inner, NewBinaryNodeCodec(nil), CompressionGzip, 0).Set(t.newNode(2, 1000)))
+
+	cp := NewCompressedNodePool(inner, NewBinaryNodeCodec(nil), CompressionFlate, 0)
+	t.NoError(cp.Set(t.newNode(3, 1000)))
+
+	var n int
+	t.NoError(cp.Traverse(func(node Node) (bool, error) {
+		t.Equal(t.newNode(parseNodeIntKey(node.Key()), 1000), node)
+		n++
+
+		return true, nil
+	}))
+	t.Equal(3, n)
+}
+
+func (t *testCompressedNodePool) TestNotCompressible() {
+	inner := NewMapNodePool(nil)
+	cp := NewCompressedNodePool(inner, NewBinaryNodeCodec(nil), CompressionGzip, 0)
+
+	node := NewBaseNode(nodeIntKey(1), 0, nil, nil, []byte{1})
+	t.NoError(cp.Set(node))
+
+	t.Equal(byte(CompressionNone), t.payload(inner, node.Key())[0])
+}
+
+func (t *testCompressedNodePool) TestCorrupted() {
+	inner := NewMapNodePool(nil)
+	cp := NewCompressedNodePool(inner, NewBinaryNodeCodec(nil), CompressionFlate, 0)
+
+	t.NoError(cp.Set(t.newNode(1, 1000)))
+
+	raw, _ := inner.Get(nodeIntKey(1))
+	p := append([]byte{}, raw.(BaseNode).Payload()...)
+	p = p[:len(p)/2]
+	t.NoError(inner.Set(raw.(BaseNode).WithPayload(p)))
+
+	_, err := cp.Get(nodeIntKey(1))
+	t.True(xerrors.Is(err, CorruptedNodeError))
+
+	var ne NodeCorruptionError
+	t.True(xerrors.As(err, &ne))
+	t.Equal(nodeIntKey(1), ne.Key)
+}
+
+func (t *testCompressedNodePool) TestDecompressedLength() {
+	inner := NewMapNodePool(nil)
+	cp := NewCompressedNodePool(inner, NewBinaryNodeCodec(nil), CompressionFlate, 0)
+
+	node := t.newNode(1, 1000)
+	t.NoError(cp.Set(node))
+
+	p := t.payload(inner, node.Key())
+	length, n := binary.Uvarint(p[1:])
+	data := p[1+n:]
+
+	for _, l := range []uint64{length - 1, length + 1, maxDecompressedSize + 1} {
+		var b [binary.MaxVarintLen64]byte
+		crafted := append([]byte{byte(CompressionFlate)}, b[:binary.PutUvarint(b[:], l)]...)
+		crafted = append(crafted, data...)
+		t.NoError(inner.Set(NewBaseNode(node.Key(), 0, nil, nil, crafted)))
+
+		_, err := cp.Get(node.Key())
+		t.True(xerrors.Is(err, CorruptedNodeError), "length=%d", l)
+		t.True(xerrors.Is(err, InvalidEncodedNodeError), "length=%d", l)
+	}
+}
+
+func (t *testCompressedNodePool) TestStacked() {
+	dir, err := ioutil.TempDir("", "avl-compressed-nodepool")
+	t.NoError(err)
+	defer os.RemoveAll(dir)
+
+	fp, err := OpenFileNodePool(filepath.Join(dir, "nodes"), NewBinaryNodeCodec(nil))
+	t.NoError(err)
+	defer fp.Close()
+
+	// NOTE checksum over compression over file
+	np := NewChecksumNodePool(
+		NewCompressedNodePool(fp, NewBinaryNodeCodec(nil), CompressionFlate, 64),
+		NewBinaryNodeCodec(nil),
+		ChecksumSHA256,
+	)
+
+	tr, err := newExampleTreeInPool(np, 20)
+	t.NoError(err)
+	t.NoError(tr.IsValid())
+}
+
+func TestCompressedNodePool(t *testing.T) {
+	suite.Run(t, new(testCompressedNodePool))
+}
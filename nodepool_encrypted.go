@@ -0,0 +1,268 @@
+package avl
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+	"sync"
+
+	"golang.org/x/xerrors"
+)
+
+var (
+	UnknownEncryptionKeyError = NewWrapError("unknown encryption key")
+)
+
+// EncryptionKeyProvider provides the AES keys of EncryptedNodePool. The key
+// should be 16, 24 or 32 bytes. The key of id should not be changed once it's
+// used.
+type EncryptionKeyProvider interface {
+	// CurrentKey returns the key for the new records.
+	CurrentKey() (uint32 /* id */, []byte, error)
+	// Key returns the key by id.
+	Key(id uint32) ([]byte, error)
+}
+
+// StaticEncryptionKeyProvider is the EncryptionKeyProvider with the fixed keys.
+type StaticEncryptionKeyProvider struct {
+	current uint32
+	keys    map[uint32][]byte
+}
+
+func NewStaticEncryptionKeyProvider(current uint32, keys map[uint32][]byte) StaticEncryptionKeyProvider {
+	return StaticEncryptionKeyProvider{current: current, keys: keys}
+}
+
+func (sp StaticEncryptionKeyProvider) CurrentKey() (uint32, []byte, error) {
+	key, err := sp.Key(sp.current)
+
+	return sp.current, key, err
+}
+
+func (sp StaticEncryptionKeyProvider) Key(id uint32) ([]byte, error) {
+	key, found := sp.keys[id]
+	if !found {
+		return nil, UnknownEncryptionKeyError.Wrapf("id=%d", id)
+	}
+
+	return key, nil
+}
+
+// EncryptedNodePool encrypts the encoded node with AES-GCM by the key of
+// EncryptionKeyProvider. The stored payload is,
+//
+//	key id(uint32) | nonce(12 bytes) | encrypted node
+//
+// The key of node in the inner NodePool is used as the additional data, so
+// the record, which is moved to the other key, can not be decrypted.
+//
+// If the key encryption key is given, the keys of node are also encrypted
+// deterministically; the nonce is derived from the key of node by HMAC-SHA256,
+// so the same key of node is always encrypted to the same key and Get() still
+// works. The key encryption key can not be rotated.
+//
+// The keys of EncryptionKeyProvider can be rotated by ReEncrypt(); it
+// re-encrypts the records, which are encrypted by the previous keys, with the
+// current key.
+type EncryptedNodePool struct {
+	*codecNodePool
+	sync.Mutex
+	keys    EncryptionKeyProvider
+	aeads   map[uint32]cipher.AEAD
+	keyAEAD cipher.AEAD
+	keyMAC  []byte
+}
+
+// NewEncryptedNodePool returns new EncryptedNodePool. If keyEncryptionKey is
+// nil, the keys of node are not encrypted.
+func NewEncryptedNodePool(
+	np NodePool, codec NodeCodec, keys EncryptionKeyProvider, keyEncryptionKey []byte,
+) (*EncryptedNodePool, error) {
+	ep := &EncryptedNodePool{
+		keys:  keys,
+		aeads: map[uint32]cipher.AEAD{},
+	}
+	ep.codecNodePool = &codecNodePool{
+		np:     np,
+		codec:  codec,
+		encode: ep.encrypt,
+		decode: ep.decrypt,
+	}
+
+	if keyEncryptionKey != nil {
+		if len(keyEncryptionKey) < 1 {
+			return nil, xerrors.Errorf("empty key encryption key")
+		}
+
+		aead, err := newAEAD(deriveKey(keyEncryptionKey, "avl-node-key-encryption"))
+		if err != nil {
+			return nil, err
+		}
+
+		ep.keyAEAD = aead
+		ep.keyMAC = deriveKey(keyEncryptionKey, "avl-node-key-nonce")
+		ep.codecNodePool.key = ep.encryptKey
+	}
+
+	if _, _, err := ep.currentAEAD(); err != nil {
+		return nil, err
+	}
+
+	return ep, nil
+}
+
+func deriveKey(key []byte, label string) []byte {
+	h := hmac.New(sha256.New, key)
+	_, _ = h.Write([]byte(label))
+
+	return h.Sum(nil)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func (ep *EncryptedNodePool) aead(id uint32) (cipher.AEAD, error) {
+	ep.Lock()
+	defer ep.Unlock()
+
+	if aead, found := ep.aeads[id]; found {
+		return aead, nil
+	}
+
+	key, err := ep.keys.Key(id)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	ep.aeads[id] = aead
+
+	return aead, nil
+}
+
+func (ep *EncryptedNodePool) currentAEAD() (uint32, cipher.AEAD, error) {
+	id, _, err := ep.keys.CurrentKey()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	aead, err := ep.aead(id)
+
+	return id, aead, err
+}
+
+// encryptKey encrypts the key of node deterministically.
+func (ep *EncryptedNodePool) encryptKey(key []byte) ([]byte, error) {
+	h := hmac.New(sha256.New, ep.keyMAC)
+	_, _ = h.Write(key)
+	nonce := h.Sum(nil)[:ep.keyAEAD.NonceSize()]
+
+	return ep.keyAEAD.Seal(nonce, nonce, key, nil), nil
+}
+
+func (ep *EncryptedNodePool) encrypt(key, b []byte) ([]byte, error) {
+	id, aead, err := ep.currentAEAD()
+	if err != nil {
+		return nil, err
+	}
+
+	return ep.seal(id, aead, key, b)
+}
+
+func (ep *EncryptedNodePool) seal(id uint32, aead cipher.AEAD, key, b []byte) ([]byte, error) {
+	p := make([]byte, 4+aead.NonceSize(), 4+aead.NonceSize()+len(b)+aead.Overhead())
+	binary.BigEndian.PutUint32(p[:4], id)
+
+	nonce := p[4:]
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(p, nonce, b, key), nil
+}
+
+func (ep *EncryptedNodePool) decrypt(key, p []byte) ([]byte, error) {
+	_, b, err := ep.open(key, p)
+
+	return b, err
+}
+
+func (ep *EncryptedNodePool) open(key, p []byte) (uint32, []byte, error) {
+	if len(p) < 4 {
+		return 0, nil, InvalidEncodedNodeError.Wrapf("too short payload; length=%d", len(p))
+	}
+
+	id := binary.BigEndian.Uint32(p[:4])
+
+	aead, err := ep.aead(id)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if len(p) < 4+aead.NonceSize() {
+		return 0, nil, InvalidEncodedNodeError.Wrapf("too short payload; length=%d", len(p))
+	}
+
+	b, err := aead.Open(nil, p[4:4+aead.NonceSize()], p[4+aead.NonceSize():], key)
+	if err != nil {
+		return 0, nil, InvalidEncodedNodeError.Wrapf("failed to decrypt; key id=%d: %w", id, err)
+	}
+
+	return id, b, nil
+}
+
+// ReEncrypt re-encrypts the records, which are not encrypted by the current
+// key, and returns the number of re-encrypted records. The records are
+// collected by Traverse() of the inner NodePool and then stored by SetNodes().
+func (ep *EncryptedNodePool) ReEncrypt() (int, error) {
+	id, aead, err := ep.currentAEAD()
+	if err != nil {
+		return 0, err
+	}
+
+	var raws []Node
+	if err := ep.np.Traverse(func(raw Node) (bool, error) {
+		bn, ok := raw.(BaseNode)
+		if !ok {
+			return false, newCorruptedNodeError(raw.Key(), InvalidNodeError.Wrapf("not BaseNode; type=%T", raw))
+		}
+
+		kid, b, err := ep.open(raw.Key(), bn.Payload())
+		if err != nil {
+			return false, newCorruptedNodeError(raw.Key(), err)
+		} else if kid == id {
+			return true, nil
+		}
+
+		p, err := ep.seal(id, aead, raw.Key(), b)
+		if err != nil {
+			return false, err
+		}
+
+		raws = append(raws, bn.WithPayload(p))
+
+		return true, nil
+	}); err != nil {
+		return 0, err
+	}
+
+	if err := SetNodes(ep.np, raws); err != nil {
+		return 0, err
+	}
+
+	return len(raws), nil
+}
@@ -0,0 +1,148 @@
+package avl
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/xerrors"
+)
+
+type testEncryptedNodePool struct {
+	suite.Suite
+}
+
+func (t *testEncryptedNodePool) keys(current uint32) StaticEncryptionKeyProvider {
+	return NewStaticEncryptionKeyProvider(current, map[uint32][]byte{
+		1: bytes.Repeat([]byte{1}, 32),
+		2: bytes.Repeat([]byte{2}, 16),
+	})
+}
+
+func (t *testEncryptedNodePool) TestEncrypt() {
+	inner := NewMapNodePool(nil)
+
+	ep, err := NewEncryptedNodePool(inner, NewBinaryNodeCodec(nil), t.keys(1), nil)
+	t.NoError(err)
+
+	tr, err := newExampleTreeInPool(ep, 20)
+	t.NoError(err)
+	t.NoError(tr.IsValid())
+
+	// NOTE keys are not encrypted, but payload is encrypted
+	raw, err := inner.Get(nodeIntKey(3))
+	t.NoError(err)
+	t.NotNil(raw)
+
+	encoded, err := NewBinaryNodeCodec(nil).Encode(newExampleNode(3))
+	t.NoError(err)
+	t.False(bytes.Contains(raw.(BaseNode).Payload(), encoded))
+
+	// NOTE wrong key
+	wrong, err := NewEncryptedNodePool(
+		inner, NewBinaryNodeCodec(nil),
+		NewStaticEncryptionKeyProvider(1, map[uint32][]byte{1: bytes.Repeat([]byte{3}, 32)}), nil,
+	)
+	t.NoError(err)
+
+	_, err = wrong.Get(nodeIntKey(3))
+	t.True(xerrors.Is(err, CorruptedNodeError))
+}
+
+func (t *testEncryptedNodePool) TestKeyEncryption() {
+	inner := NewMapNodePool(nil)
+
+	ep, err := NewEncryptedNodePool(inner, NewBinaryNodeCodec(nil), t.keys(1), []byte("key encryption key"))
+	t.NoError(err)
+
+	tr, err := newExampleTreeInPool(ep, 20)
+	t.NoError(err)
+	t.NoError(tr.IsValid())
+
+	t.NoError(inner.Traverse(func(raw Node) (bool, error) {
+		t.False(bytes.Contains(raw.Key(), []byte("00")))
+		return true, nil
+	}))
+
+	node, err := ep.Get(nodeIntKey(3))
+	t.NoError(err)
+	t.Equal(nodeIntKey(3), node.Key())
+
+	has, err := ep.Has(nodeIntKey(3))
+	t.NoError(err)
+	t.True(has)
+
+	t.NoError(ep.Delete(nodeIntKey(3)))
+
+	has, err = ep.Has(nodeIntKey(3))
+	t.NoError(err)
+	t.False(has)
+
+	// NOTE the record moved to the other key can not be decrypted
+	sk1, err := ep.encryptKey(nodeIntKey(1))
+	t.NoError(err)
+	sk2, err := ep.encryptKey(nodeIntKey(2))
+	t.NoError(err)
+
+	raw, err := inner.Get(sk2)
+	t.NoError(err)
+	t.NoError(inner.Set(NewBaseNode(sk1, 0, nil, nil, raw.(BaseNode).Payload())))
+
+	_, err = ep.Get(nodeIntKey(1))
+	t.True(xerrors.Is(err, CorruptedNodeError))
+}
+
+func (t *testEncryptedNodePool) TestReEncrypt() {
+	inner := NewMapNodePool(nil)
+
+	ep, err := NewEncryptedNodePool(inner, NewBinaryNodeCodec(nil), t.keys(1), []byte("kek"))
+	t.NoError(err)
+
+	tr, err := newExampleTreeInPool(ep, 20)
+	t.NoError(err)
+
+	// NOTE rotate to key 2
+	ep, err = NewEncryptedNodePool(inner, NewBinaryNodeCodec(nil), t.keys(2), []byte("kek"))
+	t.NoError(err)
+	t.NoError(ep.Set(newExampleNode(100)))
+
+	n, err := ep.ReEncrypt()
+	t.NoError(err)
+	t.Equal(20, n)
+
+	n, err = ep.ReEncrypt()
+	t.NoError(err)
+	t.Equal(0, n)
+
+	// NOTE key 1 is not needed anymore
+	ep, err = NewEncryptedNodePool(
+		inner, NewBinaryNodeCodec(nil),
+		NewStaticEncryptionKeyProvider(2, map[uint32][]byte{2: bytes.Repeat([]byte{2}, 16)}), []byte("kek"),
+	)
+	t.NoError(err)
+
+	ntr, err := NewTree(tr.Root().Key(), ep)
+	t.NoError(err)
+
+	var keys int
+	t.NoError(ntr.Traverse(func(Node) (bool, error) {
+		keys++
+		return true, nil
+	}))
+	t.Equal(20, keys)
+}
+
+func (t *testEncryptedNodePool) TestUnknownKey() {
+	_, err := NewEncryptedNodePool(NewMapNodePool(nil), NewBinaryNodeCodec(nil), t.keys(3), nil)
+	t.True(xerrors.Is(err, UnknownEncryptionKeyError))
+
+	_, err = NewEncryptedNodePool(
+		NewMapNodePool(nil), NewBinaryNodeCodec(nil),
+		NewStaticEncryptionKeyProvider(1, map[uint32][]byte{1: []byte("short")}), nil,
+	)
+	t.Error(err)
+}
+
+func TestEncryptedNodePool(t *testing.T) {
+	suite.Run(t, new(testEncryptedNodePool))
+}
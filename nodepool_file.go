@@ -0,0 +1,540 @@
+package avl
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/rs/zerolog"
+)
+
+var (
+	CorruptedFileError  = NewWrapError("corrupted file")
+	ClosedNodePoolError = NewWrapError("NodePool closed")
+)
+
+var fileNodePoolHeader = []byte("avlfile\x01")
+
+const (
+	fileNodePoolRecordHeaderSize = 9 // length(4) + crc32(4) + op(1)
+
+	fileNodePoolOpSet    byte = 0x01
+	fileNodePoolOpDelete byte = 0x02
+	fileNodePoolOpBatch  byte = 0x03
+
+	fileNodePoolBatchHeaderSize = 5 // op(1) + length(4)
+)
+
+type fileNodePoolIndex struct {
+	offset int64 // offset of encoded node
+	length int
+}
+
+// FileNodePool stores the encoded nodes in the append-only log file. The file
+// is,
+//
+//	header | record | record | ...
+//
+// and each record is,
+//
+//	length(uint32) | crc32(uint32) | op(1 byte) | body
+//
+// length is the length of body and crc32 is the IEEE checksum of op and body.
+// The body of set record is the node encoded by NodeCodec and the body of
+// delete record is the key. The body of batch record has the set and delete
+// records of Apply() and SetMany(),
+//
+//	op(1 byte) | length(uint32) | body | op(1 byte) | length(uint32) | body | ...
+//
+// The batch record is checked by one checksum, so it's applied entirely or
+// not at all.
+//
+// The key and the offset of node are kept in memory and the index is rebuilt
+// from the file when it's opened. Set() and Delete() are buffered; the
+// changes are durable only after Commit(). If the last record is torn by
+// crash, it's removed when the file is opened again.
+type FileNodePool struct {
+	sync.Mutex
+	*Logger
+	f       *os.File
+	w       *bufio.Writer
+	codec   NodeCodec
+	index   map[string]fileNodePoolIndex
+	size    int64 // size of file including buffered records
+	flushed int64 // size of file, which is written to file
+}
+
+// OpenFileNodePool opens or creates the file.
+func OpenFileNodePool(path string, codec NodeCodec) (*FileNodePool, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	fp := &FileNodePool{
+		Logger: NewLogger(func(c zerolog.Context) zerolog.Context {
+			return c.Str("module", "avl_file_nodepool").Str("path", path)
+		}),
+		f:     f,
+		codec: codec,
+		index: map[string]fileNodePoolIndex{},
+	}
+
+	if err := fp.load(); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	// NOTE records are appended after the last valid record
+	if _, err := f.Seek(fp.size, io.SeekStart); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	fp.w = bufio.NewWriter(f)
+
+	return fp, nil
+}
+
+// load reads the all records and rebuilds the index. The torn record at the
+// end of file is truncated.
+func (fp *FileNodePool) load() error {
+	fi, err := fp.f.Stat()
+	if err != nil {
+		return err
+	}
+	fileSize := fi.Size()
+
+	hl := int64(len(fileNodePoolHeader))
+	if fileSize < hl {
+		// NOTE new file or torn header
+		header := make([]byte, fileSize)
+		if _, err := fp.f.ReadAt(header, 0); err != nil {
+			return err
+		} else if !bytes.HasPrefix(fileNodePoolHeader, header) {
+			return CorruptedFileError.Wrapf("unknown header; header=%x", header)
+		}
+
+		if err := fp.truncate(0); err != nil {
+			return err
+		}
+		if _, err := fp.f.WriteAt(fileNodePoolHeader, 0); err != nil {
+			return err
+		}
+		if err := fp.f.Sync(); err != nil {
+			return err
+		}
+
+		fp.size, fp.flushed = hl, hl
+
+		return nil
+	}
+
+	r := bufio.NewReader(io.NewSectionReader(fp.f, 0, fileSize))
+
+	header := make([]byte, hl)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return err
+	} else if !bytes.Equal(header, fileNodePoolHeader) {
+		return CorruptedFileError.Wrapf("unknown header; header=%x", header)
+	}
+
+	offset := hl
+	rh := make([]byte, fileNodePoolRecordHeaderSize)
+	for offset < fileSize {
+		if _, err := io.ReadFull(r, rh); err != nil {
+			return fp.recover(offset, fileSize, err)
+		}
+
+		length := int64(binary.BigEndian.Uint32(rh[:4]))
+		end := offset + fileNodePoolRecordHeaderSize + length
+		if end > fileSize {
+			return fp.recover(offset, fileSize, io.ErrUnexpectedEOF)
+		}
+
+		body := make([]byte, length)
+		if _, err := io.ReadFull(r, body); err != nil {
+			return fp.recover(offset, fileSize, err)
+		}
+
+		if crc := fileNodePoolChecksum(rh[8], body); crc != binary.BigEndian.Uint32(rh[4:8]) {
+			if end == fileSize {
+				return fp.recover(offset, fileSize, CorruptedFileError.Wrapf("checksum not match"))
+			}
+
+			return CorruptedFileError.Wrapf("checksum not match; offset=%d", offset)
+		}
+
+		if rh[8] == fileNodePoolOpBatch {
+			if err := fp.loadBatch(offset+fileNodePoolRecordHeaderSize, body); err != nil {
+				return err
+			}
+		} else if err := fp.loadRecord(offset+fileNodePoolRecordHeaderSize, rh[8], body); err != nil {
+			return err
+		}
+
+		offset = end
+	}
+
+	fp.size, fp.flushed = offset, offset
+
+	fp.Log().Debug().Int("nodes", len(fp.index)).Int64("size", offset).Msg("file loaded")
+
+	return nil
+}
+
+// loadRecord applies the set or delete record to the index. offset is the
+// offset of body.
+func (fp *FileNodePool) loadRecord(offset int64, op byte, body []byte) error {
+	switch op {
+	case fileNodePoolOpSet:
+		node, err := fp.codec.Decode(body)
+		if err != nil {
+			return CorruptedFileError.Wrapf("failed to decode node; offset=%d: %w", offset, err)
+		}
+
+		fp.index[string(node.Key())] = fileNodePoolIndex{offset: offset, length: len(body)}
+	case fileNodePoolOpDelete:
+		delete(fp.index, string(body))
+	default:
+		return CorruptedFileError.Wrapf("unknown op; offset=%d op=%d", offset, op)
+	}
+
+	return nil
+}
+
+// loadBatch applies the records in the body of batch record. offset is the
+// offset of body.
+func (fp *FileNodePool) loadBatch(offset int64, body []byte) error {
+	for i := 0; i < len(body); {
+		if len(body)-i < fileNodePoolBatchHeaderSize {
+			return CorruptedFileError.Wrapf("too short batch record; offset=%d", offset+int64(i))
+		}
+
+		op := body[i]
+		length := int(binary.BigEndian.Uint32(body[i+1 : i+fileNodePoolBatchHeaderSize]))
+
+		start := i + fileNodePoolBatchHeaderSize
+		if length > len(body)-start {
+			return CorruptedFileError.Wrapf("invalid length in batch record; offset=%d", offset+int64(i))
+		}
+
+		if err := fp.loadRecord(offset+int64(start), op, body[start:start+length]); err != nil {
+			return err
+		}
+
+		i = start + length
+	}
+
+	return nil
+}
+
+// recover truncates the torn record at offset.
+func (fp *FileNodePool) recover(offset, fileSize int64, err error) error {
+	fp.Log().Warn().Err(err).Int64("offset", offset).Int64("size", fileSize).Msg("torn record found; truncated")
+
+	if err := fp.truncate(offset); err != nil {
+		return err
+	}
+
+	fp.size, fp.flushed = offset, offset
+
+	return nil
+}
+
+func (fp *FileNodePool) truncate(size int64) error {
+	if err := fp.f.Truncate(size); err != nil {
+		return err
+	}
+
+	return fp.f.Sync()
+}
+
+func (fp *FileNodePool) Get(key []byte) (Node, error) {
+	fp.Lock()
+	defer fp.Unlock()
+
+	return fp.get(key)
+}
+
+func (fp *FileNodePool) get(key []byte) (Node, error) {
+	if fp.f == nil {
+		return nil, ClosedNodePoolError.Wrapf("key=%x", key)
+	}
+
+	idx, found := fp.index[string(key)]
+	if !found {
+		return nil, nil
+	}
+
+	// NOTE the buffered record should be written before reading
+	if idx.offset >= fp.flushed {
+		if err := fp.flush(); err != nil {
+			return nil, err
+		}
+	}
+
+	b := make([]byte, idx.length)
+	if _, err := fp.f.ReadAt(b, idx.offset); err != nil {
+		return nil, err
+	}
+
+	return fp.codec.Decode(b)
+}
+
+func (fp *FileNodePool) GetMany(keys [][]byte) ([]Node, error) {
+	fp.Lock()
+	defer fp.Unlock()
+
+	nodes := make([]Node, len(keys))
+	for i, key := range keys {
+		if key == nil {
+			continue
+		}
+
+		node, err := fp.get(key)
+		if err != nil {
+			return nil, err
+		}
+		nodes[i] = node
+	}
+
+	return nodes, nil
+}
+
+func (fp *FileNodePool) Set(node Node) error {
+	fp.Lock()
+	defer fp.Unlock()
+
+	return fp.set(node)
+}
+
+// SetMany writes the nodes in one batch record.
+func (fp *FileNodePool) SetMany(nodes []Node) error {
+	return fp.Apply(nodes, nil)
+}
+
+// Apply writes the nodes and the deleted keys in one batch record, so after
+// crash, the all changes or none of them are applied.
+func (fp *FileNodePool) Apply(nodes []Node, deleted [][]byte) error {
+	fp.Lock()
+	defer fp.Unlock()
+
+	if len(nodes)+len(deleted) < 1 {
+		return nil
+	}
+
+	encoded := make([][]byte, len(nodes))
+
+	size := (len(nodes) + len(deleted)) * fileNodePoolBatchHeaderSize
+	for i := range nodes {
+		b, err := fp.codec.Encode(nodes[i])
+		if err != nil {
+			return err
+		}
+
+		encoded[i] = b
+		size += len(b)
+	}
+
+	for i := range deleted {
+		size += len(deleted[i])
+	}
+
+	body := make([]byte, 0, size)
+	offsets := make([]int, len(nodes))
+
+	var bh [fileNodePoolBatchHeaderSize]byte
+	for i := range encoded {
+		bh[0] = fileNodePoolOpSet
+		binary.BigEndian.PutUint32(bh[1:], uint32(len(encoded[i])))
+
+		body = append(body, bh[:]...)
+		offsets[i] = len(body)
+		body = append(body, encoded[i]...)
+	}
+
+	for i := range deleted {
+		bh[0] = fileNodePoolOpDelete
+		binary.BigEndian.PutUint32(bh[1:], uint32(len(deleted[i])))
+
+		body = append(append(body, bh[:]...), deleted[i]...)
+	}
+
+	offset, err := fp.write(fileNodePoolOpBatch, body)
+	if err != nil {
+		return err
+	}
+
+	for i := range nodes {
+		fp.index[string(nodes[i].Key())] = fileNodePoolIndex{
+			offset: offset + int64(offsets[i]),
+			length: len(encoded[i]),
+		}
+	}
+
+	for i := range deleted {
+		delete(fp.index, string(deleted[i]))
+	}
+
+	return nil
+}
+
+func (fp *FileNodePool) set(node Node) error {
+	b, err := fp.codec.Encode(node)
+	if err != nil {
+		return err
+	}
+
+	offset, err := fp.write(fileNodePoolOpSet, b)
+	if err != nil {
+		return err
+	}
+
+	fp.index[string(node.Key())] = fileNodePoolIndex{offset: offset, length: len(b)}
+
+	return nil
+}
+
+// Delete appends the delete record. The space of removed node is not
+// reclaimed.
+func (fp *FileNodePool) Delete(key []byte) error {
+	fp.Lock()
+	defer fp.Unlock()
+
+	if _, found := fp.index[string(key)]; !found {
+		return nil
+	}
+
+	if _, err := fp.write(fileNodePoolOpDelete, key); err != nil {
+		return err
+	}
+
+	delete(fp.index, string(key))
+
+	return nil
+}
+
+// write appends record and returns the offset of body.
+func (fp *FileNodePool) write(op byte, body []byte) (int64, error) {
+	if fp.f == nil {
+		return 0, ClosedNodePoolError.Wrapf("failed to write")
+	}
+
+	rh := make([]byte, fileNodePoolRecordHeaderSize)
+	binary.BigEndian.PutUint32(rh[:4], uint32(len(body)))
+	binary.BigEndian.PutUint32(rh[4:8], fileNodePoolChecksum(op, body))
+	rh[8] = op
+
+	if _, err := fp.w.Write(rh); err != nil {
+		return 0, err
+	}
+	if _, err := fp.w.Write(body); err != nil {
+		return 0, err
+	}
+
+	offset := fp.size + fileNodePoolRecordHeaderSize
+	fp.size = offset + int64(len(body))
+
+	return offset, nil
+}
+
+func (fp *FileNodePool) flush() error {
+	if err := fp.w.Flush(); err != nil {
+		return err
+	}
+
+	fp.flushed = fp.size
+
+	return nil
+}
+
+func (fp *FileNodePool) Has(key []byte) (bool, error) {
+	fp.Lock()
+	defer fp.Unlock()
+
+	_, found := fp.index[string(key)]
+
+	return found, nil
+}
+
+func (fp *FileNodePool) Len() (int, error) {
+	fp.Lock()
+	defer fp.Unlock()
+
+	return len(fp.index), nil
+}
+
+// Traverse traverses the nodes, which are set before Traverse() is called.
+func (fp *FileNodePool) Traverse(f NodeTraverseFunc) error {
+	fp.Lock()
+	keys := make([][]byte, 0, len(fp.index))
+	for k := range fp.index {
+		keys = append(keys, []byte(k))
+	}
+	fp.Unlock()
+
+	for _, key := range keys {
+		node, err := fp.Get(key)
+		if err != nil {
+			return err
+		} else if node == nil { // NOTE removed while traversing
+			continue
+		}
+
+		if keep, err := f(node); err != nil {
+			return err
+		} else if !keep {
+			break
+		}
+	}
+
+	return nil
+}
+
+// Commit writes the buffered records to file and calls fsync.
+func (fp *FileNodePool) Commit() error {
+	fp.Lock()
+	defer fp.Unlock()
+
+	return fp.commit()
+}
+
+func (fp *FileNodePool) commit() error {
+	if fp.f == nil {
+		return ClosedNodePoolError.Wrapf("failed to commit")
+	}
+
+	if err := fp.flush(); err != nil {
+		return err
+	}
+
+	return fp.f.Sync()
+}
+
+// Close commits and closes the file.
+func (fp *FileNodePool) Close() error {
+	fp.Lock()
+	defer fp.Unlock()
+
+	if fp.f == nil {
+		return nil
+	}
+
+	if err := fp.commit(); err != nil {
+		return err
+	}
+
+	err := fp.f.Close()
+	fp.f = nil
+
+	return err
+}
+
+func fileNodePoolChecksum(op byte, body []byte) uint32 {
+	return crc32.Update(crc32.ChecksumIEEE([]byte{op}), crc32.IEEETable, body)
+}
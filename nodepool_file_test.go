@@ -0,0 +1,251 @@
+package avl
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/xerrors"
+)
+
+type testFileNodePool struct {
+	suite.Suite
+	dir string
+}
+
+func (t *testFileNodePool) SetupTest() {
+	dir, err := ioutil.TempDir("", "avl-file-nodepool")
+	t.NoError(err)
+
+	t.dir = dir
+}
+
+func (t *testFileNodePool) TearDownTest() {
+	_ = os.RemoveAll(t.dir)
+}
+
+func (t *testFileNodePool) path() string {
+	return filepath.Join(t.dir, "nodes")
+}
+
+func (t *testFileNodePool) open() *FileNodePool {
+	fp, err := OpenFileNodePool(t.path(), NewBinaryNodeCodec(nil))
+	t.NoError(err)
+
+	return fp
+}
+
+func (t *testFileNodePool) TestReopen() {
+	tr, err := newExampleTree(30)
+	t.NoError(err)
+
+	fp := t.open()
+	t.NoError(tr.Traverse(func(node Node) (bool, error) {
+		return true, fp.Set(node)
+	}))
+	t.NoError(fp.Close())
+
+	fp = t.open()
+	defer fp.Close()
+
+	n, err := fp.Len()
+	t.NoError(err)
+	t.Equal(30, n)
+
+	ftr, err := NewTree(tr.Root().Key(), fp)
+	t.NoError(err)
+	t.NoError(ftr.IsValid())
+
+	for i := 0; i < 30; i++ {
+		node, err := ftr.Get(nodeIntKey(i))
+		t.NoError(err)
+		t.NotNil(node)
+		t.Equal(nodeIntKey(i), node.Key())
+	}
+}
+
+func (t *testFileNodePool) TestBuffered() {
+	fp := t.open()
+	defer fp.Close()
+
+	t.NoError(fp.Set(newExampleNode(10)))
+
+	// NOTE not committed, but it can be read
+	node, err := fp.Get(nodeIntKey(10))
+	t.NoError(err)
+	t.Equal(nodeIntKey(10), node.Key())
+
+	// NOTE override
+	override := newExampleNode(10)
+	override.height = 2
+	t.NoError(fp.Set(override))
+
+	node, err = fp.Get(nodeIntKey(10))
+	t.NoError(err)
+	t.Equal(int16(2), node.Height())
+
+	t.NoError(fp.Commit())
+
+	fi, err := os.Stat(t.path())
+	t.NoError(err)
+	t.Equal(fp.size, fi.Size())
+}
+
+func (t *testFileNodePool) TestDelete() {
+	fp := t.open()
+	t.NoError(fp.SetMany([]Node{newExampleNode(10), newExampleNode(20)}))
+	t.NoError(fp.Delete(nodeIntKey(10)))
+	t.NoError(fp.Close())
+
+	fp = t.open()
+	defer fp.Close()
+
+	has, err := fp.Has(nodeIntKey(10))
+	t.NoError(err)
+	t.False(has)
+
+	has, err = fp.Has(nodeIntKey(20))
+	t.NoError(err)
+	t.True(has)
+}
+
+func (t *testFileNodePool) TestTornRecord() {
+	fp := t.open()
+	t.NoError(fp.Set(newExampleNode(10)))
+	t.NoError(fp.Commit())
+
+	size := fp.size
+
+	t.NoError(fp.Set(newExampleNode(20)))
+	t.NoError(fp.Close())
+
+	b, err := ioutil.ReadFile(t.path())
+	t.NoError(err)
+
+	for i := size + 1; i < int64(len(b)); i++ {
+		t.NoError(ioutil.WriteFile(t.path(), b[:i], 0o600))
+
+		fp = t.open()
+
+		node, err := fp.Get(nodeIntKey(10))
+		t.NoError(err)
+		t.NotNil(node)
+
+		node, err = fp.Get(nodeIntKey(20))
+		t.NoError(err)
+		t.Nil(node, "size=%d", i)
+
+		t.Equal(size, fp.size)
+		t.NoError(fp.Close())
+
+		tfi, err := os.Stat(t.path())
+		t.NoError(err)
+		t.Equal(size, tfi.Size())
+	}
+}
+
+func (t *testFileNodePool) TestTornChecksum() {
+	fp := t.open()
+	t.NoError(fp.Set(newExampleNode(10)))
+	t.NoError(fp.Set(newExampleNode(20)))
+	t.NoError(fp.Close())
+
+	b, err := ioutil.ReadFile(t.path())
+	t.NoError(err)
+
+	// NOTE broken last record is removed
+	b[len(b)-1] ^= 0xff
+	t.NoError(ioutil.WriteFile(t.path(), b, 0o600))
+
+	fp = t.open()
+	defer fp.Close()
+
+	n, err := fp.Len()
+	t.NoError(err)
+	t.Equal(1, n)
+}
+
+func (t *testFileNodePool) TestApply() {
+	fp := t.open()
+	t.NoError(fp.Set(newExampleNode(10)))
+	t.NoError(fp.Commit())
+
+	size := fp.size
+
+	t.NoError(fp.Apply([]Node{newExampleNode(20), newExampleNode(30)}, [][]byte{nodeIntKey(10)}))
+	t.NoError(fp.Close())
+
+	b, err := ioutil.ReadFile(t.path())
+	t.NoError(err)
+
+	// NOTE torn batch record is not applied at all
+	for i := size + 1; i < int64(len(b)); i++ {
+		t.NoError(ioutil.WriteFile(t.path(), b[:i], 0o600))
+
+		fp = t.open()
+
+		keys, err := fp.Len()
+		t.NoError(err)
+		t.Equal(1, keys, "size=%d", i)
+
+		has, err := fp.Has(nodeIntKey(10))
+		t.NoError(err)
+		t.True(has, "size=%d", i)
+
+		t.NoError(fp.Close())
+	}
+
+	t.NoError(ioutil.WriteFile(t.path(), b, 0o600))
+
+	fp = t.open()
+	defer fp.Close()
+
+	for _, c := range []struct {
+		i     int
+		found bool
+	}{{10, false}, {20, true}, {30, true}} {
+		node, err := fp.Get(nodeIntKey(c.i))
+		t.NoError(err)
+		t.Equal(c.found, node != nil, "key=%d", c.i)
+	}
+}
+
+func (t *testFileNodePool) TestCorrupted() {
+	fp := t.open()
+	t.NoError(fp.Set(newExampleNode(10)))
+	t.NoError(fp.Set(newExampleNode(20)))
+	t.NoError(fp.Close())
+
+	b, err := ioutil.ReadFile(t.path())
+	t.NoError(err)
+
+	// NOTE broken record in the middle
+	b[len(fileNodePoolHeader)+fileNodePoolRecordHeaderSize] ^= 0xff
+	t.NoError(ioutil.WriteFile(t.path(), b, 0o600))
+
+	_, err = OpenFileNodePool(t.path(), NewBinaryNodeCodec(nil))
+	t.True(xerrors.Is(err, CorruptedFileError))
+
+	// NOTE unknown file
+	t.NoError(ioutil.WriteFile(t.path(), []byte("unknown file"), 0o600))
+
+	_, err = OpenFileNodePool(t.path(), NewBinaryNodeCodec(nil))
+	t.True(xerrors.Is(err, CorruptedFileError))
+}
+
+func (t *testFileNodePool) TestClosed() {
+	fp := t.open()
+	t.NoError(fp.Close())
+
+	_, err := fp.Get(nodeIntKey(10))
+	t.True(xerrors.Is(err, ClosedNodePoolError))
+
+	err = fp.Set(newExampleNode(10))
+	t.True(xerrors.Is(err, ClosedNodePoolError))
+}
+
+func TestFileNodePool(t *testing.T) {
+	suite.Run(t, new(testFileNodePool))
+}
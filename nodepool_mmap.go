@@ -0,0 +1,397 @@
+package avl
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/rs/zerolog"
+)
+
+var mmapNodePoolHeader = []byte("avlmmap\x01")
+
+const mmapNodePoolHeaderSize = 32 // magic(8) + count(8) + index offset(8) + root offset(8)
+
+// WriteMmapFile writes the nodes of Tree to the file for MmapNodePool. The
+// file is,
+//
+//	header | record | record | ... | index
+//
+// The header is,
+//
+//	magic(8 bytes) | count(uint64) | index offset(uint64) | root offset(uint64)
+//
+// and each record is,
+//
+//	key length(uint32) | key | node length(uint32) | node
+//
+// The node is encoded by NodeCodec. The records are sorted by key and the
+// index has the offsets of records in uint64. Only the nodes, which are
+// reachable from root, are written; the file is written to the temporary file
+// and then renamed, so the existing file is not broken by failure.
+func WriteMmapFile(path string, tr *Tree, codec NodeCodec) error {
+	if tr.Root() == nil {
+		return InvalidTreeError.Wrapf("empty tree")
+	}
+
+	var keys [][]byte
+	if err := tr.Traverse(func(node Node) (bool, error) {
+		keys = append(keys, node.Key())
+		return true, nil
+	}); err != nil {
+		return err
+	}
+
+	sort.Slice(keys, func(i, j int) bool { return CompareKey(keys[i], keys[j]) < 0 })
+
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+
+	if err := writeMmapFile(f, tr, keys, codec); err != nil {
+		_ = f.Close()
+		_ = os.Remove(tmp)
+
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+func writeMmapFile(f *os.File, tr *Tree, keys [][]byte, codec NodeCodec) error {
+	w := bufio.NewWriter(f)
+
+	header := make([]byte, mmapNodePoolHeaderSize)
+	copy(header, mmapNodePoolHeader)
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+
+	offsets := make([]uint64, len(keys))
+	offset := uint64(mmapNodePoolHeaderSize)
+
+	var rootOffset uint64
+	var l [4]byte
+	for i, key := range keys {
+		node, err := tr.NodePool().Get(key)
+		if err != nil {
+			return err
+		} else if node == nil {
+			return NodeNotFoundInPoolError.Wrapf("key=%x", key)
+		}
+
+		b, err := codec.Encode(node)
+		if err != nil {
+			return err
+		}
+
+		offsets[i] = offset
+		if EqualKey(key, tr.Root().Key()) {
+			rootOffset = offset
+		}
+
+		for _, v := range [][]byte{key, b} {
+			binary.BigEndian.PutUint32(l[:], uint32(len(v)))
+			if _, err := w.Write(l[:]); err != nil {
+				return err
+			}
+			if _, err := w.Write(v); err != nil {
+				return err
+			}
+		}
+
+		offset += uint64(8 + len(key) + len(b))
+	}
+
+	var o [8]byte
+	for _, i := range offsets {
+		binary.BigEndian.PutUint64(o[:], i)
+		if _, err := w.Write(o[:]); err != nil {
+			return err
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	binary.BigEndian.PutUint64(header[8:16], uint64(len(keys)))
+	binary.BigEndian.PutUint64(header[16:24], offset)
+	binary.BigEndian.PutUint64(header[24:32], rootOffset)
+	if _, err := f.WriteAt(header, 0); err != nil {
+		return err
+	}
+
+	return f.Sync()
+}
+
+// MmapNodePool is the read-only NodePool over the memory-mapped file, which is
+// written by WriteMmapFile(). Get() finds node by binary search over the
+// index and decodes node from the mapped memory, so if NodeCodec does not copy
+// the input like BinaryNodeCodec, the keys of node refer the mapped memory
+// directly. The nodes should not be used after Close().
+//
+// In the platform, which does not support mmap, the whole file is loaded to
+// memory.
+type MmapNodePool struct {
+	sync.RWMutex
+	*Logger
+	b       []byte
+	codec   NodeCodec
+	count   int
+	index   []byte
+	rootKey []byte
+}
+
+// OpenMmapNodePool maps the file to memory.
+func OpenMmapNodePool(path string, codec NodeCodec) (*MmapNodePool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	} else if fi.Size() < mmapNodePoolHeaderSize {
+		return nil, CorruptedFileError.Wrapf("too short file; size=%d", fi.Size())
+	}
+
+	b, err := mmapFile(f, int(fi.Size()))
+	if err != nil {
+		return nil, err
+	}
+
+	mp := &MmapNodePool{
+		Logger: NewLogger(func(c zerolog.Context) zerolog.Context {
+			return c.Str("module", "avl_mmap_nodepool").Str("path", path)
+		}),
+		b:     b,
+		codec: codec,
+	}
+
+	if err := mp.load(); err != nil {
+		_ = munmapFile(b)
+		return nil, err
+	}
+
+	return mp, nil
+}
+
+func (mp *MmapNodePool) load() error {
+	if !bytes.Equal(mp.b[:len(mmapNodePoolHeader)], mmapNodePoolHeader) {
+		return CorruptedFileError.Wrapf("unknown header; header=%x", mp.b[:len(mmapNodePoolHeader)])
+	}
+
+	count := binary.BigEndian.Uint64(mp.b[8:16])
+	indexOffset := binary.BigEndian.Uint64(mp.b[16:24])
+	rootOffset := binary.BigEndian.Uint64(mp.b[24:32])
+
+	size := uint64(len(mp.b))
+	if indexOffset < mmapNodePoolHeaderSize || indexOffset > size || (size-indexOffset)/8 != count ||
+		(size-indexOffset)%8 != 0 {
+		return CorruptedFileError.Wrapf("invalid index; count=%d offset=%d size=%d", count, indexOffset, size)
+	}
+
+	mp.count = int(count)
+	mp.index = mp.b[indexOffset:]
+
+	if count > 0 {
+		key, _, err := mp.record(rootOffset)
+		if err != nil {
+			return err
+		}
+		mp.rootKey = key
+	}
+
+	mp.Log().Debug().Int("nodes", mp.count).Msg("file mapped")
+
+	return nil
+}
+
+// record returns the key and the encoded node of record at offset.
+func (mp *MmapNodePool) record(offset uint64) ([]byte, []byte, error) {
+	var vs [2][]byte
+	for i := range vs {
+		if offset+4 > uint64(len(mp.b)) {
+			return nil, nil, CorruptedFileError.Wrapf("invalid record; offset=%d", offset)
+		}
+
+		l := uint64(binary.BigEndian.Uint32(mp.b[offset : offset+4]))
+		offset += 4
+
+		if offset+l > uint64(len(mp.b)) {
+			return nil, nil, CorruptedFileError.Wrapf("invalid record; offset=%d length=%d", offset, l)
+		}
+
+		vs[i] = mp.b[offset : offset+l : offset+l]
+		offset += l
+	}
+
+	return vs[0], vs[1], nil
+}
+
+func (mp *MmapNodePool) recordAt(i int) ([]byte, []byte, error) {
+	return mp.record(binary.BigEndian.Uint64(mp.index[i*8 : i*8+8]))
+}
+
+// RootKey returns the key of root of written Tree.
+func (mp *MmapNodePool) RootKey() []byte {
+	return mp.rootKey
+}
+
+func (mp *MmapNodePool) Get(key []byte) (Node, error) {
+	mp.RLock()
+	defer mp.RUnlock()
+
+	return mp.get(key)
+}
+
+func (mp *MmapNodePool) get(key []byte) (Node, error) {
+	if mp.b == nil {
+		return nil, ClosedNodePoolError.Wrapf("key=%x", key)
+	}
+
+	i, b, err := mp.search(key)
+	if err != nil || i < 0 {
+		return nil, err
+	}
+
+	return mp.codec.Decode(b)
+}
+
+// search finds the record by binary search. If not found, it returns -1.
+func (mp *MmapNodePool) search(key []byte) (int, []byte, error) {
+	var err error
+	i := sort.Search(mp.count, func(i int) bool {
+		if err != nil {
+			return true
+		}
+
+		k, _, e := mp.recordAt(i)
+		if e != nil {
+			err = e
+			return true
+		}
+
+		return CompareKey(k, key) >= 0
+	})
+
+	if err != nil {
+		return -1, nil, err
+	} else if i >= mp.count {
+		return -1, nil, nil
+	}
+
+	k, b, err := mp.recordAt(i)
+	if err != nil {
+		return -1, nil, err
+	} else if !EqualKey(k, key) {
+		return -1, nil, nil
+	}
+
+	return i, b, nil
+}
+
+func (mp *MmapNodePool) GetMany(keys [][]byte) ([]Node, error) {
+	mp.RLock()
+	defer mp.RUnlock()
+
+	nodes := make([]Node, len(keys))
+	for i, key := range keys {
+		if key == nil {
+			continue
+		}
+
+		node, err := mp.get(key)
+		if err != nil {
+			return nil, err
+		}
+		nodes[i] = node
+	}
+
+	return nodes, nil
+}
+
+// Set returns ReadOnlyNodePoolError.
+func (mp *MmapNodePool) Set(node Node) error {
+	return ReadOnlyNodePoolError.Wrapf("key=%x", node.Key())
+}
+
+// SetMany returns ReadOnlyNodePoolError.
+func (mp *MmapNodePool) SetMany([]Node) error {
+	return ReadOnlyNodePoolError.Wrapf("failed to set nodes")
+}
+
+func (mp *MmapNodePool) Has(key []byte) (bool, error) {
+	mp.RLock()
+	defer mp.RUnlock()
+
+	if mp.b == nil {
+		return false, ClosedNodePoolError.Wrapf("key=%x", key)
+	}
+
+	i, _, err := mp.search(key)
+
+	return i >= 0, err
+}
+
+func (mp *MmapNodePool) Len() (int, error) {
+	return mp.count, nil
+}
+
+// Traverse traverses the nodes in the order of key.
+func (mp *MmapNodePool) Traverse(f NodeTraverseFunc) error {
+	mp.RLock()
+	defer mp.RUnlock()
+
+	if mp.b == nil {
+		return ClosedNodePoolError.Wrapf("failed to traverse")
+	}
+
+	for i := 0; i < mp.count; i++ {
+		_, b, err := mp.recordAt(i)
+		if err != nil {
+			return err
+		}
+
+		node, err := mp.codec.Decode(b)
+		if err != nil {
+			return err
+		}
+
+		if keep, err := f(node); err != nil {
+			return err
+		} else if !keep {
+			break
+		}
+	}
+
+	return nil
+}
+
+// Close unmaps the file.
+func (mp *MmapNodePool) Close() error {
+	mp.Lock()
+	defer mp.Unlock()
+
+	if mp.b == nil {
+		return nil
+	}
+
+	err := munmapFile(mp.b)
+	mp.b = nil
+	mp.index = nil
+
+	return err
+}
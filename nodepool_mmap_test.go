@@ -0,0 +1,134 @@
+package avl
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/xerrors"
+)
+
+type testMmapNodePool struct {
+	suite.Suite
+	dir string
+}
+
+func (t *testMmapNodePool) SetupTest() {
+	dir, err := ioutil.TempDir("", "avl-mmap-nodepool")
+	t.NoError(err)
+
+	t.dir = dir
+}
+
+func (t *testMmapNodePool) TearDownTest() {
+	_ = os.RemoveAll(t.dir)
+}
+
+func (t *testMmapNodePool) path() string {
+	return filepath.Join(t.dir, "nodes")
+}
+
+func (t *testMmapNodePool) write(n int) *Tree {
+	tr, err := newExampleTreeInPool(NewMapNodePool(nil), n)
+	t.NoError(err)
+
+	// NOTE orphan node is not written
+	t.NoError(tr.NodePool().Set(newExampleMutableNode(n + 10)))
+
+	t.NoError(WriteMmapFile(t.path(), tr, NewBinaryNodeCodec(nil)))
+
+	return tr
+}
+
+func (t *testMmapNodePool) TestLoad() {
+	tr := t.write(100)
+
+	mp, err := OpenMmapNodePool(t.path(), NewBinaryNodeCodec(nil))
+	t.NoError(err)
+	defer mp.Close()
+
+	t.Equal(tr.Root().Key(), mp.RootKey())
+
+	n, err := mp.Len()
+	t.NoError(err)
+	t.Equal(100, n)
+
+	mtr, err := NewTree(mp.RootKey(), mp)
+	t.NoError(err)
+	t.NoError(mtr.IsValid())
+
+	for i := 0; i < 100; i++ {
+		node, err := mtr.Get(nodeIntKey(i))
+		t.NoError(err)
+		t.Equal(nodeIntKey(i), node.Key())
+	}
+
+	node, err := mp.Get(nodeIntKey(110))
+	t.NoError(err)
+	t.Nil(node)
+
+	has, err := mp.Has(nodeIntKey(3))
+	t.NoError(err)
+	t.True(has)
+
+	// NOTE traversed in the order of key
+	var keys []int
+	t.NoError(mp.Traverse(func(node Node) (bool, error) {
+		keys = append(keys, parseNodeIntKey(node.Key()))
+		return len(keys) < 5, nil
+	}))
+	t.Equal([]int{0, 1, 2, 3, 4}, keys)
+}
+
+func (t *testMmapNodePool) TestReadOnly() {
+	_ = t.write(10)
+
+	mp, err := OpenMmapNodePool(t.path(), NewBinaryNodeCodec(nil))
+	t.NoError(err)
+	defer mp.Close()
+
+	err = mp.Set(newExampleNode(3))
+	t.True(xerrors.Is(err, ReadOnlyNodePoolError))
+
+	err = SetNodes(mp, []Node{newExampleNode(3)})
+	t.True(xerrors.Is(err, ReadOnlyNodePoolError))
+
+	_, err = NewGarbageCollector(mp).Collect(mp.RootKey())
+	t.True(xerrors.Is(err, NotDeletableNodePoolError))
+}
+
+func (t *testMmapNodePool) TestCorrupted() {
+	_ = t.write(10)
+
+	b, err := ioutil.ReadFile(t.path())
+	t.NoError(err)
+
+	// NOTE broken index
+	t.NoError(ioutil.WriteFile(t.path(), b[:len(b)-3], 0o600))
+
+	_, err = OpenMmapNodePool(t.path(), NewBinaryNodeCodec(nil))
+	t.True(xerrors.Is(err, CorruptedFileError))
+
+	// NOTE unknown file
+	t.NoError(ioutil.WriteFile(t.path(), make([]byte, 100), 0o600))
+
+	_, err = OpenMmapNodePool(t.path(), NewBinaryNodeCodec(nil))
+	t.True(xerrors.Is(err, CorruptedFileError))
+}
+
+func (t *testMmapNodePool) TestClosed() {
+	_ = t.write(10)
+
+	mp, err := OpenMmapNodePool(t.path(), NewBinaryNodeCodec(nil))
+	t.NoError(err)
+	t.NoError(mp.Close())
+
+	_, err = mp.Get(nodeIntKey(3))
+	t.True(xerrors.Is(err, ClosedNodePoolError))
+}
+
+func TestMmapNodePool(t *testing.T) {
+	suite.Run(t, new(testMmapNodePool))
+}
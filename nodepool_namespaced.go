@@ -0,0 +1,313 @@
+package avl
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+
+	"golang.org/x/xerrors"
+)
+
+// namespacePrefix returns the prefix of namespace; the length of namespace in
+// uvarint and namespace. By the length, the namespace "a" with the key "bc"
+// does not collide with the namespace "ab" with the key "c".
+func namespacePrefix(namespace []byte) []byte {
+	var l [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(l[:], uint64(len(namespace)))
+
+	return append(l[:n:n], namespace...)
+}
+
+// parseNamespace returns the namespace of the prefixed key.
+func parseNamespace(key []byte) ([]byte, bool) {
+	l, n := binary.Uvarint(key)
+	if n <= 0 || l > uint64(len(key)-n) {
+		return nil, false
+	}
+
+	return key[n : n+int(l)], true
+}
+
+// namespacedNode is the node in the inner NodePool of NamespacedNodePool. The
+// key and the leaf keys are prefixed.
+type namespacedNode struct {
+	Node
+	prefix []byte
+}
+
+func (nn namespacedNode) prefixed(key []byte) []byte {
+	if key == nil {
+		return nil
+	}
+
+	k := make([]byte, len(nn.prefix)+len(key))
+	copy(k, nn.prefix)
+	copy(k[len(nn.prefix):], key)
+
+	return k
+}
+
+func (nn namespacedNode) Key() []byte {
+	return nn.prefixed(nn.Node.Key())
+}
+
+func (nn namespacedNode) LeftKey() []byte {
+	return nn.prefixed(nn.Node.LeftKey())
+}
+
+func (nn namespacedNode) RightKey() []byte {
+	return nn.prefixed(nn.Node.RightKey())
+}
+
+// strippedNode is the node from the inner NodePool, which is not
+// namespacedNode; the prefix of keys is removed.
+type strippedNode struct {
+	Node
+	prefix int
+}
+
+func (sn strippedNode) strip(key []byte) []byte {
+	if key == nil {
+		return nil
+	}
+
+	return key[sn.prefix:]
+}
+
+func (sn strippedNode) Key() []byte {
+	return sn.strip(sn.Node.Key())
+}
+
+func (sn strippedNode) LeftKey() []byte {
+	return sn.strip(sn.Node.LeftKey())
+}
+
+func (sn strippedNode) RightKey() []byte {
+	return sn.strip(sn.Node.RightKey())
+}
+
+// NamespacedNodePool shares the inner NodePool with the other trees. The keys
+// of node are prefixed by the namespace, so the trees of different namespaces
+// do not collide. The nodes in the inner NodePool also refer their leaves by
+// the prefixed keys, so the inner NodePool still has the valid trees.
+//
+// If the inner NodePool decodes node from bytes, the BaseNode is stored with
+// the prefixed keys and the payload, so BinaryNodeCodec without PayloadCodec
+// keeps the payload.
+//
+// Traverse() traverses the all nodes of the inner NodePool and filters the
+// nodes of namespace.
+type NamespacedNodePool struct {
+	np        NodePool
+	namespace []byte
+	prefix    []byte
+}
+
+func NewNamespacedNodePool(np NodePool, namespace []byte) *NamespacedNodePool {
+	return &NamespacedNodePool{
+		np:        np,
+		namespace: namespace,
+		prefix:    namespacePrefix(namespace),
+	}
+}
+
+// NodePool returns the inner NodePool.
+func (np *NamespacedNodePool) NodePool() NodePool {
+	return np.np
+}
+
+// Namespace returns the namespace.
+func (np *NamespacedNodePool) Namespace() []byte {
+	return np.namespace
+}
+
+func (np *NamespacedNodePool) key(key []byte) []byte {
+	return namespacedNode{prefix: np.prefix}.prefixed(key)
+}
+
+func (np *NamespacedNodePool) wrap(node Node) Node {
+	if bn, ok := node.(BaseNode); ok {
+		nn := namespacedNode{Node: bn, prefix: np.prefix}
+		return NewBaseNode(nn.Key(), bn.Height(), nn.LeftKey(), nn.RightKey(), bn.Payload())
+	}
+
+	return namespacedNode{Node: node, prefix: np.prefix}
+}
+
+func (np *NamespacedNodePool) unwrap(node Node) (Node, error) {
+	if !bytes.HasPrefix(node.Key(), np.prefix) {
+		return nil, InvalidNodeError.Wrapf("not in namespace; namespace=%x key=%x", np.namespace, node.Key())
+	}
+
+	switch t := node.(type) {
+	case namespacedNode:
+		return t.Node, nil
+	case BaseNode:
+		sn := strippedNode{Node: t, prefix: len(np.prefix)}
+		return NewBaseNode(sn.Key(), t.Height(), sn.LeftKey(), sn.RightKey(), t.Payload()), nil
+	default:
+		return strippedNode{Node: node, prefix: len(np.prefix)}, nil
+	}
+}
+
+func (np *NamespacedNodePool) Get(key []byte) (Node, error) {
+	node, err := np.np.Get(np.key(key))
+	if err != nil || node == nil {
+		return nil, err
+	}
+
+	return np.unwrap(node)
+}
+
+func (np *NamespacedNodePool) GetMany(keys [][]byte) ([]Node, error) {
+	pks := make([][]byte, len(keys))
+	for i := range keys {
+		pks[i] = np.key(keys[i])
+	}
+
+	nodes, err := GetNodes(np.np, pks)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range nodes {
+		if nodes[i] == nil {
+			continue
+		}
+
+		if nodes[i], err = np.unwrap(nodes[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return nodes, nil
+}
+
+func (np *NamespacedNodePool) Set(node Node) error {
+	return np.np.Set(np.wrap(node))
+}
+
+func (np *NamespacedNodePool) SetMany(nodes []Node) error {
+	wrapped := make([]Node, len(nodes))
+	for i := range nodes {
+		wrapped[i] = np.wrap(nodes[i])
+	}
+
+	return SetNodes(np.np, wrapped)
+}
+
+// Apply applies the changes with the prefixed keys by the inner NodePool. The
+// inner NodePool should implement AtomicNodePool.
+func (np *NamespacedNodePool) Apply(nodes []Node, deleted [][]byte) error {
+	wrapped := make([]Node, len(nodes))
+	for i := range nodes {
+		wrapped[i] = np.wrap(nodes[i])
+	}
+
+	pks := make([][]byte, len(deleted))
+	for i := range deleted {
+		pks[i] = np.key(deleted[i])
+	}
+
+	return ApplyNodes(np.np, wrapped, pks)
+}
+
+// Delete removes node from the inner NodePool. The inner NodePool should
+// implement DeletableNodePool.
+func (np *NamespacedNodePool) Delete(key []byte) error {
+	dp, ok := np.np.(DeletableNodePool)
+	if !ok {
+		return NotDeletableNodePoolError.Wrapf("type=%T", np.np)
+	}
+
+	return dp.Delete(np.key(key))
+}
+
+func (np *NamespacedNodePool) Has(key []byte) (bool, error) {
+	return HasNode(np.np, np.key(key))
+}
+
+// Len counts the nodes of namespace by Traverse().
+func (np *NamespacedNodePool) Len() (int, error) {
+	var n int
+	if err := np.Traverse(func(Node) (bool, error) {
+		n++
+		return true, nil
+	}); err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
+
+// Traverse traverses only the nodes of namespace.
+func (np *NamespacedNodePool) Traverse(f NodeTraverseFunc) error {
+	return np.np.Traverse(func(node Node) (bool, error) {
+		if !bytes.HasPrefix(node.Key(), np.prefix) {
+			return true, nil
+		}
+
+		n, err := np.unwrap(node)
+		if err != nil {
+			return false, err
+		}
+
+		return f(n)
+	})
+}
+
+// Namespaces returns the namespaces in NodePool in sorted order. The keys,
+// which are not prefixed by NamespacedNodePool, are ignored.
+func Namespaces(np NodePool) ([][]byte, error) {
+	found := map[string]struct{}{}
+	if err := np.Traverse(func(node Node) (bool, error) {
+		if ns, ok := parseNamespace(node.Key()); ok {
+			found[string(ns)] = struct{}{}
+		}
+
+		return true, nil
+	}); err != nil {
+		return nil, err
+	}
+
+	namespaces := make([][]byte, 0, len(found))
+	for ns := range found {
+		namespaces = append(namespaces, []byte(ns))
+	}
+
+	sort.Slice(namespaces, func(i, j int) bool {
+		return bytes.Compare(namespaces[i], namespaces[j]) < 0
+	})
+
+	return namespaces, nil
+}
+
+// DropNamespace removes the all nodes of namespace and returns the number of
+// removed nodes. NodePool should implement DeletableNodePool.
+func DropNamespace(np NodePool, namespace []byte) (int, error) {
+	dp, ok := np.(DeletableNodePool)
+	if !ok {
+		return 0, NotDeletableNodePoolError.Wrapf("type=%T", np)
+	}
+
+	prefix := namespacePrefix(namespace)
+
+	var keys [][]byte
+	if err := np.Traverse(func(node Node) (bool, error) {
+		if bytes.HasPrefix(node.Key(), prefix) {
+			keys = append(keys, node.Key())
+		}
+
+		return true, nil
+	}); err != nil {
+		return 0, err
+	}
+
+	for _, key := range keys {
+		if err := dp.Delete(key); err != nil {
+			return 0, xerrors.Errorf("failed to drop namespace; namespace=%x: %w", namespace, err)
+		}
+	}
+
+	return len(keys), nil
+}
@@ -0,0 +1,205 @@
+package avl
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/xerrors"
+)
+
+type testNamespacedNodePool struct {
+	suite.Suite
+}
+
+func (t *testNamespacedNodePool) store(np NodePool, tr *Tree) {
+	t.NoError(tr.Traverse(func(node Node) (bool, error) {
+		return true, np.Set(node)
+	}))
+}
+
+func (t *testNamespacedNodePool) TestTrees() {
+	inner := NewMapNodePool(nil)
+
+	a, err := newExampleTree(10)
+	t.NoError(err)
+	b, err := newExampleTree(20)
+	t.NoError(err)
+
+	na := NewNamespacedNodePool(inner, []byte("a"))
+	nb := NewNamespacedNodePool(inner, []byte("b"))
+	t.store(na, a)
+	t.store(nb, b)
+
+	n, err := inner.Len()
+	t.NoError(err)
+	t.Equal(30, n)
+
+	for _, c := range []struct {
+		tr *Tree
+		np *NamespacedNodePool
+	}{{a, na}, {b, nb}} {
+		tr, err := NewTree(c.tr.Root().Key(), c.np)
+		t.NoError(err)
+		t.NoError(tr.IsValid())
+
+		t.Equal(c.tr.Root().Key(), tr.Root().Key())
+		t.Equal(c.tr.Root().Height(), tr.Root().Height())
+
+		n, err := c.np.Len()
+		t.NoError(err)
+
+		var keys int
+		t.NoError(tr.Traverse(func(node Node) (bool, error) {
+			keys++
+			return true, nil
+		}))
+		t.Equal(n, keys)
+	}
+
+	// NOTE the inner NodePool still has the tree by the prefixed keys
+	tr, err := NewTree(na.key(a.Root().Key()), inner)
+	t.NoError(err)
+
+	node, err := tr.Get(na.key(nodeIntKey(3)))
+	t.NoError(err)
+	t.Equal(na.key(nodeIntKey(3)), node.Key())
+
+	node, err = na.Get(nodeIntKey(15))
+	t.NoError(err)
+	t.Nil(node)
+
+	node, err = nb.Get(nodeIntKey(15))
+	t.NoError(err)
+	t.Equal(nodeIntKey(15), node.Key())
+}
+
+func (t *testNamespacedNodePool) TestTraverse() {
+	inner := NewMapNodePool(nil)
+	t.NoError(inner.Set(newExampleNode(100)))
+
+	na := NewNamespacedNodePool(inner, []byte("a"))
+	nb := NewNamespacedNodePool(inner, []byte("b"))
+	a, err := newExampleTree(10)
+	t.NoError(err)
+	b, err := newExampleTree(5)
+	t.NoError(err)
+
+	t.store(na, a)
+	t.store(nb, b)
+
+	found := map[int]struct{}{}
+	t.NoError(nb.Traverse(func(node Node) (bool, error) {
+		found[parseNodeIntKey(node.Key())] = struct{}{}
+		return true, nil
+	}))
+	t.Equal(5, len(found))
+	for i := 0; i < 5; i++ {
+		t.Contains(found, i)
+	}
+
+	n, err := na.Len()
+	t.NoError(err)
+	t.Equal(10, n)
+
+	has, err := nb.Has(nodeIntKey(7))
+	t.NoError(err)
+	t.False(has)
+
+	nodes, err := na.GetMany([][]byte{nodeIntKey(7), nodeIntKey(100)})
+	t.NoError(err)
+	t.Equal(nodeIntKey(7), nodes[0].Key())
+	t.Nil(nodes[1])
+}
+
+func (t *testNamespacedNodePool) TestPrefixCollision() {
+	inner := NewMapNodePool(nil)
+
+	na := NewNamespacedNodePool(inner, []byte("a"))
+	nab := NewNamespacedNodePool(inner, []byte("ab"))
+
+	t.NoError(na.Set(NewBaseNode([]byte("bc"), 0, nil, nil, []byte("a"))))
+	t.NoError(nab.Set(NewBaseNode([]byte("c"), 0, nil, nil, []byte("ab"))))
+
+	node, err := na.Get([]byte("bc"))
+	t.NoError(err)
+	t.Equal([]byte("a"), node.(BaseNode).Payload())
+
+	node, err = nab.Get([]byte("bc"))
+	t.NoError(err)
+	t.Nil(node)
+
+	node, err = nab.Get([]byte("c"))
+	t.NoError(err)
+	t.Equal([]byte("ab"), node.(BaseNode).Payload())
+}
+
+func (t *testNamespacedNodePool) TestNamespacesAndDrop() {
+	inner := NewMapNodePool(nil)
+
+	tr, err := newExampleTree(10)
+	t.NoError(err)
+
+	for _, ns := range []string{"c", "a", "b"} {
+		t.store(NewNamespacedNodePool(inner, []byte(ns)), tr)
+	}
+
+	namespaces, err := Namespaces(inner)
+	t.NoError(err)
+	t.Equal([][]byte{[]byte("a"), []byte("b"), []byte("c")}, namespaces)
+
+	n, err := DropNamespace(inner, []byte("b"))
+	t.NoError(err)
+	t.Equal(10, n)
+
+	namespaces, err = Namespaces(inner)
+	t.NoError(err)
+	t.Equal([][]byte{[]byte("a"), []byte("c")}, namespaces)
+
+	n, err = CountNodes(inner)
+	t.NoError(err)
+	t.Equal(20, n)
+
+	_, err = DropNamespace(struct{ NodePool }{inner}, []byte("a"))
+	t.True(xerrors.Is(err, NotDeletableNodePoolError))
+}
+
+func (t *testNamespacedNodePool) TestFileNodePool() {
+	dir, err := ioutil.TempDir("", "avl-namespaced-")
+	t.NoError(err)
+	defer os.RemoveAll(dir)
+
+	fp, err := OpenFileNodePool(filepath.Join(dir, "nodes"), NewBinaryNodeCodec(nil))
+	t.NoError(err)
+	defer fp.Close()
+
+	a, err := newExampleTree(10)
+	t.NoError(err)
+
+	na := NewNamespacedNodePool(fp, []byte("a"))
+	nb := NewNamespacedNodePool(fp, []byte("b"))
+	t.store(na, a)
+
+	payload := NewBaseNode(nodeIntKey(1), 0, nil, nil, []byte("payload"))
+	t.NoError(nb.Set(payload))
+
+	tr, err := NewTree(a.Root().Key(), na)
+	t.NoError(err)
+	t.NoError(tr.IsValid())
+
+	node, err := nb.Get(nodeIntKey(1))
+	t.NoError(err)
+	t.Equal(payload, node)
+
+	t.NoError(nb.Delete(nodeIntKey(1)))
+
+	namespaces, err := Namespaces(fp)
+	t.NoError(err)
+	t.Equal([][]byte{[]byte("a")}, namespaces)
+}
+
+func TestNamespacedNodePool(t *testing.T) {
+	suite.Run(t, new(testNamespacedNodePool))
+}
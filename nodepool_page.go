@@ -0,0 +1,1057 @@
+package avl
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/rs/zerolog"
+	"golang.org/x/xerrors"
+)
+
+// DefaultPageSize is the default page size of PageNodePool.
+const DefaultPageSize = 4096
+
+const (
+	minPageSize = 128
+	maxPageSize = 1 << 24
+
+	pageHeaderSize     = 16 // crc32(4) + type(1) + flags(1) + reserved(2) + next(4) + length(4)
+	pageHeadHeaderSize = 14 // seq(8) + node length(4) + key length(2)
+	pageMetaSize       = 20 // magic(8) + page size(4) + stable seq(8)
+
+	pageTypeFree     byte = 0x00
+	pageTypeHead     byte = 0x01
+	pageTypeOverflow byte = 0x02
+	pageTypeBatch    byte = 0x03
+
+	pageFlagBatch byte = 0x01 // head page written by Apply()
+)
+
+var pageNodePoolMeta = []byte("avlpage\x01")
+
+type pageNodePoolIndex struct {
+	page uint32
+	seq  uint64
+}
+
+// PageNodePool stores the encoded nodes in the fixed-size pages of file. The
+// first page is the meta page,
+//
+//	magic(8 bytes) | page size(uint32) | stable seq(uint64)
+//
+// and the others are,
+//
+//	crc32(uint32) | type(1 byte) | flags(1 byte) | reserved(2 bytes) | next(uint32) | length(uint32) | data
+//
+// The node record starts at the head page and continues to the overflow pages
+// by next. The data of head page starts with,
+//
+//	seq(uint64) | node length(uint32) | key length(uint16) | key
+//
+// and the rest of head page and the overflow pages have the node encoded by
+// NodeCodec. seq increases at every Set(); when the same key is found in the
+// multiple head pages, which can happen by crash, the greatest seq wins.
+//
+// Only the key and the head page of node are kept in memory; the index and
+// the free pages are rebuilt by scanning the page headers when it's opened.
+// The node is read from file at every Get(), so the tree, which is larger than
+// memory, can be loaded lazily by Tree.Get().
+//
+// The pages of removed or overwritten node are reused by the next Set(), but
+// they are reused only after Commit(), so the committed node is not
+// overwritten until the new one is committed. Compact() rewrites the file
+// without free pages.
+//
+// Apply() writes the head pages with the batch flag and, after fsync, the
+// batch record, which has the range of seq and the deleted keys, like node
+// record. The head page with the batch flag is valid only if it's seq is not
+// greater than the stable seq of meta page or it's in the range of batch
+// record, so after crash the all changes of Apply() or none of them are
+// applied. Commit() moves the stable seq to the last seq and then the batch
+// records are freed.
+type PageNodePool struct {
+	sync.Mutex
+	*Logger
+	path     string
+	f        *os.File
+	codec    NodeCodec
+	pageSize int
+	index    map[string]pageNodePoolIndex
+	pages    uint32   // number of pages including meta page
+	free     []uint32 // sorted in descending order; the lowest page is reused first
+	pending  []uint32 // freed, but not committed
+	released []uint32 // head pages of removed or overwritten nodes, which are cleared by Commit()
+	batches  []uint32 // pages of batch records, which are freed by Commit()
+	seq      uint64
+	stable   uint64 // the head pages with the batch flag are valid until stable
+}
+
+// OpenPageNodePool opens or creates the file. pageSize is used only for new
+// file; if pageSize is 0, DefaultPageSize is used.
+func OpenPageNodePool(path string, codec NodeCodec, pageSize int) (*PageNodePool, error) {
+	if pageSize == 0 {
+		pageSize = DefaultPageSize
+	} else if pageSize < minPageSize || pageSize > maxPageSize {
+		return nil, xerrors.Errorf("invalid page size; page size=%d, not in [%d, %d]", pageSize, minPageSize, maxPageSize)
+	}
+
+	pp := &PageNodePool{
+		Logger: NewLogger(func(c zerolog.Context) zerolog.Context {
+			return c.Str("module", "avl_page_nodepool").Str("path", path)
+		}),
+		path:     path,
+		codec:    codec,
+		pageSize: pageSize,
+	}
+
+	if err := pp.open(); err != nil {
+		return nil, err
+	}
+
+	return pp, nil
+}
+
+func (pp *PageNodePool) open() error {
+	f, err := os.OpenFile(pp.path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+
+	pp.f = f
+	pp.index = map[string]pageNodePoolIndex{}
+	pp.free = nil
+	pp.pending = nil
+	pp.released = nil
+	pp.batches = nil
+	pp.seq = 0
+	pp.stable = 0
+
+	if err := pp.load(); err != nil {
+		_ = f.Close()
+		pp.f = nil
+
+		return err
+	}
+
+	return nil
+}
+
+func (pp *PageNodePool) load() error {
+	fi, err := pp.f.Stat()
+	if err != nil {
+		return err
+	}
+
+	if fi.Size() < pageMetaSize {
+		if fi.Size() > 0 {
+			return CorruptedFileError.Wrapf("too short meta page; size=%d", fi.Size())
+		}
+
+		return pp.writeMeta()
+	}
+
+	meta := make([]byte, pageMetaSize)
+	if _, err := pp.f.ReadAt(meta, 0); err != nil {
+		return err
+	} else if !bytes.Equal(meta[:len(pageNodePoolMeta)], pageNodePoolMeta) {
+		return CorruptedFileError.Wrapf("unknown meta; meta=%x", meta[:len(pageNodePoolMeta)])
+	}
+	pp.pageSize = int(binary.BigEndian.Uint32(meta[len(pageNodePoolMeta):]))
+	if pp.pageSize < minPageSize || pp.pageSize > maxPageSize {
+		return CorruptedFileError.Wrapf("invalid page size in meta; page size=%d", pp.pageSize)
+	}
+	pp.stable = binary.BigEndian.Uint64(meta[len(pageNodePoolMeta)+4:])
+
+	size := fi.Size()
+	if torn := size % int64(pp.pageSize); torn != 0 {
+		pp.Log().Warn().Int64("size", size).Int64("torn", torn).Msg("torn page found; truncated")
+
+		size -= torn
+		if err := pp.f.Truncate(size); err != nil {
+			return err
+		}
+	}
+
+	pp.pages = uint32(size / int64(pp.pageSize))
+
+	return pp.scan()
+}
+
+func (pp *PageNodePool) writeMeta() error {
+	b := make([]byte, pp.pageSize)
+	copy(b, pageNodePoolMeta)
+	binary.BigEndian.PutUint32(b[len(pageNodePoolMeta):], uint32(pp.pageSize))
+
+	if _, err := pp.f.WriteAt(b, 0); err != nil {
+		return err
+	}
+
+	pp.pages = 1
+
+	return pp.f.Sync()
+}
+
+// writeStable writes the stable seq to meta page.
+func (pp *PageNodePool) writeStable(seq uint64) error {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, seq)
+
+	if _, err := pp.f.WriteAt(b, int64(len(pageNodePoolMeta)+4)); err != nil {
+		return err
+	}
+
+	if err := pp.f.Sync(); err != nil {
+		return err
+	}
+
+	pp.stable = seq
+
+	return nil
+}
+
+type pageInfo struct {
+	typ  byte
+	next uint32
+}
+
+type pageHeadInfo struct {
+	page  uint32
+	seq   uint64
+	key   string
+	flags byte
+}
+
+type pageBatch struct {
+	seq     uint64
+	first   uint64
+	deleted [][]byte
+}
+
+// coveredByBatch checks the head page of seq is written by the one of
+// batches.
+func coveredByBatch(batches []pageBatch, seq uint64) bool {
+	for _, bt := range batches {
+		if seq >= bt.first && seq < bt.seq {
+			return true
+		}
+	}
+
+	return false
+}
+
+// scan reads the all pages and rebuilds the index and the free pages. The
+// head page, which is broken, and the pages, which is not referred by any
+// head page, become free. The head page, which is superseded by the greater
+// seq, is cleared in file; otherwise it can be revived after the new one is
+// removed. The head page of the incomplete batch is also cleared and the
+// complete batches are applied and then the stable seq is moved.
+func (pp *PageNodePool) scan() error {
+	infos := make([]pageInfo, pp.pages)
+
+	var heads []pageHeadInfo
+	var batchPages []uint32
+
+	b := make([]byte, pp.pageSize)
+	for i := uint32(1); i < pp.pages; i++ {
+		if _, err := pp.f.ReadAt(b, pp.offset(i)); err != nil {
+			return err
+		}
+
+		h, err := pp.parsePage(b)
+		if err != nil {
+			pp.Log().Warn().Err(err).Uint32("page", i).Msg("broken page found; ignored")
+			continue
+		}
+
+		infos[i] = pageInfo{typ: h.typ, next: h.next}
+		if h.typ == pageTypeBatch {
+			batchPages = append(batchPages, i)
+			continue
+		} else if h.typ != pageTypeHead {
+			continue
+		}
+
+		seq, _, key, err := parseHeadPage(h.data)
+		if err != nil {
+			pp.Log().Warn().Err(err).Uint32("page", i).Msg("broken head page found; ignored")
+			infos[i].typ = pageTypeFree
+			continue
+		}
+
+		heads = append(heads, pageHeadInfo{page: i, seq: seq, key: string(key), flags: h.flags})
+	}
+
+	batches := pp.scanBatches(batchPages)
+
+	pp.seq = pp.stable
+
+	var superseded []uint32
+	for _, hd := range heads {
+		if hd.seq > pp.seq {
+			pp.seq = hd.seq
+		}
+
+		if hd.flags&pageFlagBatch != 0 && hd.seq > pp.stable && !coveredByBatch(batches, hd.seq) {
+			pp.Log().Warn().Uint32("page", hd.page).Msg("head page of incomplete batch found; ignored")
+			superseded = append(superseded, hd.page)
+			continue
+		}
+
+		if idx, found := pp.index[hd.key]; found {
+			if idx.seq > hd.seq {
+				superseded = append(superseded, hd.page)
+				continue
+			}
+
+			superseded = append(superseded, idx.page)
+		}
+		pp.index[hd.key] = pageNodePoolIndex{page: hd.page, seq: hd.seq}
+	}
+
+	for _, bt := range batches {
+		if bt.seq > pp.seq {
+			pp.seq = bt.seq
+		}
+
+		for _, key := range bt.deleted {
+			if idx, found := pp.index[string(key)]; found && idx.seq < bt.seq {
+				superseded = append(superseded, idx.page)
+				delete(pp.index, string(key))
+			}
+		}
+	}
+
+	if len(superseded) > 0 {
+		for _, page := range superseded {
+			if err := pp.writePage(page, pageTypeFree, 0, 0, nil); err != nil {
+				return err
+			}
+		}
+
+		if err := pp.f.Sync(); err != nil {
+			return err
+		}
+
+		pp.Log().Debug().Int("pages", len(superseded)).Msg("superseded head pages cleared")
+	}
+
+	// NOTE the batch records are not needed after the stable seq is moved, so
+	// they become free.
+	if len(batches) > 0 {
+		if err := pp.writeStable(pp.seq); err != nil {
+			return err
+		}
+
+		pp.Log().Debug().Int("batches", len(batches)).Msg("batches recovered")
+	}
+
+	used := make([]bool, pp.pages)
+	used[0] = true
+	for _, idx := range pp.index {
+		for p := idx.page; p != 0 && p < pp.pages && !used[p]; p = infos[p].next {
+			used[p] = true
+		}
+	}
+
+	for i := pp.pages - 1; i > 0; i-- {
+		if !used[i] {
+			pp.free = append(pp.free, i)
+		}
+	}
+
+	pp.Log().Debug().
+		Int("nodes", len(pp.index)).Uint32("pages", pp.pages).Int("free", len(pp.free)).
+		Msg("file loaded")
+
+	return nil
+}
+
+// scanBatches reads the batch records, which are not stable yet. The broken
+// batch record is ignored, so the head pages of it become invalid.
+func (pp *PageNodePool) scanBatches(pages []uint32) []pageBatch {
+	var batches []pageBatch
+	for _, page := range pages {
+		seq, b, err := pp.readRecord(page, pageTypeBatch, nil)
+		if err != nil {
+			pp.Log().Warn().Err(err).Uint32("page", page).Msg("broken batch found; ignored")
+			continue
+		} else if seq <= pp.stable {
+			continue
+		}
+
+		bt, err := parseBatch(seq, b)
+		if err != nil {
+			pp.Log().Warn().Err(err).Uint32("page", page).Msg("broken batch found; ignored")
+			continue
+		}
+
+		batches = append(batches, bt)
+	}
+
+	sort.Slice(batches, func(i, j int) bool { return batches[i].seq < batches[j].seq })
+
+	return batches
+}
+
+// encodeBatch encodes the batch record,
+//
+//	first seq(uint64) | count(uint32) | key length(uint16) | key | ...
+func encodeBatch(first uint64, deleted [][]byte) ([]byte, error) {
+	b := make([]byte, 12)
+	binary.BigEndian.PutUint64(b[:8], first)
+	binary.BigEndian.PutUint32(b[8:12], uint32(len(deleted)))
+
+	for _, key := range deleted {
+		if len(key) > 0xffff {
+			return nil, InvalidNodeError.Wrapf("key too long; key length=%d", len(key))
+		}
+
+		var l [2]byte
+		binary.BigEndian.PutUint16(l[:], uint16(len(key)))
+		b = append(b, l[:]...)
+		b = append(b, key...)
+	}
+
+	return b, nil
+}
+
+func parseBatch(seq uint64, b []byte) (pageBatch, error) {
+	if len(b) < 12 {
+		return pageBatch{}, CorruptedFileError.Wrapf("too short batch")
+	}
+
+	bt := pageBatch{seq: seq, first: binary.BigEndian.Uint64(b[:8])}
+
+	count := int(binary.BigEndian.Uint32(b[8:12]))
+	b = b[12:]
+	for i := 0; i < count; i++ {
+		if len(b) < 2 {
+			return pageBatch{}, CorruptedFileError.Wrapf("too short batch key")
+		}
+
+		l := int(binary.BigEndian.Uint16(b[:2]))
+		if len(b) < 2+l {
+			return pageBatch{}, CorruptedFileError.Wrapf("too short batch key")
+		}
+
+		bt.deleted = append(bt.deleted, b[2:2+l])
+		b = b[2+l:]
+	}
+
+	return bt, nil
+}
+
+func (pp *PageNodePool) offset(page uint32) int64 {
+	return int64(page) * int64(pp.pageSize)
+}
+
+type pageHeader struct {
+	typ   byte
+	flags byte
+	next  uint32
+	data  []byte
+}
+
+func (pp *PageNodePool) parsePage(b []byte) (pageHeader, error) {
+	typ := b[4]
+	if typ == pageTypeFree {
+		return pageHeader{typ: typ}, nil
+	}
+
+	length := binary.BigEndian.Uint32(b[12:16])
+	if int(length) > len(b)-pageHeaderSize {
+		return pageHeader{}, CorruptedFileError.Wrapf("invalid length of page; length=%d", length)
+	}
+
+	end := pageHeaderSize + int(length)
+	if crc32.ChecksumIEEE(b[4:end]) != binary.BigEndian.Uint32(b[:4]) {
+		return pageHeader{}, CorruptedFileError.Wrapf("checksum not match")
+	}
+
+	if typ != pageTypeHead && typ != pageTypeOverflow && typ != pageTypeBatch {
+		return pageHeader{}, CorruptedFileError.Wrapf("unknown page type; type=%d", typ)
+	}
+
+	return pageHeader{
+		typ:   typ,
+		flags: b[5],
+		next:  binary.BigEndian.Uint32(b[8:12]),
+		data:  b[pageHeaderSize:end],
+	}, nil
+}
+
+func parseHeadPage(data []byte) (uint64, int, []byte, error) {
+	if len(data) < pageHeadHeaderSize {
+		return 0, 0, nil, CorruptedFileError.Wrapf("too short head page")
+	}
+
+	seq := binary.BigEndian.Uint64(data[:8])
+	length := int(binary.BigEndian.Uint32(data[8:12]))
+	kl := int(binary.BigEndian.Uint16(data[12:14]))
+	if kl > len(data)-pageHeadHeaderSize {
+		return 0, 0, nil, CorruptedFileError.Wrapf("invalid key length; length=%d", kl)
+	}
+
+	return seq, length, data[pageHeadHeaderSize : pageHeadHeaderSize+kl], nil
+}
+
+func (pp *PageNodePool) Get(key []byte) (Node, error) {
+	pp.Lock()
+	defer pp.Unlock()
+
+	return pp.get(key)
+}
+
+func (pp *PageNodePool) get(key []byte) (Node, error) {
+	if pp.f == nil {
+		return nil, ClosedNodePoolError.Wrapf("key=%x", key)
+	}
+
+	b, err := pp.read(key)
+	if err != nil || b == nil {
+		return nil, err
+	}
+
+	return pp.codec.Decode(b)
+}
+
+// read reads the encoded node from the pages.
+func (pp *PageNodePool) read(key []byte) ([]byte, error) {
+	idx, found := pp.index[string(key)]
+	if !found {
+		return nil, nil
+	}
+
+	_, b, err := pp.readRecord(idx.page, pageTypeHead, key)
+
+	return b, err
+}
+
+// readRecord reads the record, which starts at the head page of typ, and
+// returns the seq and the data.
+func (pp *PageNodePool) readRecord(head uint32, typ byte, key []byte) (uint64, []byte, error) {
+	page := make([]byte, pp.pageSize)
+
+	var seq uint64
+	var b []byte
+	var length int
+	for p, i := head, 0; ; i++ {
+		if p == 0 || p >= pp.pages {
+			return 0, nil, CorruptedFileError.Wrapf("invalid page in chain; key=%x page=%d", key, p)
+		}
+
+		if _, err := pp.f.ReadAt(page, pp.offset(p)); err != nil {
+			return 0, nil, err
+		}
+
+		h, err := pp.parsePage(page)
+		if err != nil {
+			return 0, nil, CorruptedFileError.Wrapf("key=%x page=%d: %w", key, p, err)
+		}
+
+		data := h.data
+		if i == 0 {
+			if h.typ != typ {
+				return 0, nil, CorruptedFileError.Wrapf("not head page; key=%x page=%d", key, p)
+			}
+
+			s, l, k, err := parseHeadPage(data)
+			if err != nil {
+				return 0, nil, err
+			} else if !bytes.Equal(k, key) {
+				return 0, nil, CorruptedFileError.Wrapf("key not match; key=%x page=%d", key, p)
+			}
+
+			seq = s
+			length = l
+			b = make([]byte, 0, length)
+			data = data[pageHeadHeaderSize+len(k):]
+		} else if h.typ != pageTypeOverflow {
+			return 0, nil, CorruptedFileError.Wrapf("not overflow page; key=%x page=%d", key, p)
+		}
+
+		b = append(b, data...)
+		if len(b) >= length || h.next == 0 {
+			break
+		}
+
+		p = h.next
+	}
+
+	if len(b) != length {
+		return 0, nil, CorruptedFileError.Wrapf("length not match; key=%x length=%d != %d", key, len(b), length)
+	}
+
+	return seq, b, nil
+}
+
+func (pp *PageNodePool) GetMany(keys [][]byte) ([]Node, error) {
+	pp.Lock()
+	defer pp.Unlock()
+
+	nodes := make([]Node, len(keys))
+	for i, key := range keys {
+		if key == nil {
+			continue
+		}
+
+		node, err := pp.get(key)
+		if err != nil {
+			return nil, err
+		}
+		nodes[i] = node
+	}
+
+	return nodes, nil
+}
+
+func (pp *PageNodePool) Set(node Node) error {
+	pp.Lock()
+	defer pp.Unlock()
+
+	return pp.set(node)
+}
+
+func (pp *PageNodePool) SetMany(nodes []Node) error {
+	pp.Lock()
+	defer pp.Unlock()
+
+	for _, node := range nodes {
+		if err := pp.set(node); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Apply writes the nodes and the batch record, which has the deleted keys, and
+// then updates the index. The pages written by the failed Apply() are freed by
+// Commit().
+func (pp *PageNodePool) Apply(nodes []Node, deleted [][]byte) error {
+	pp.Lock()
+	defer pp.Unlock()
+
+	if pp.f == nil {
+		return ClosedNodePoolError.Wrapf("failed to apply")
+	}
+
+	bs := make([][]byte, len(nodes))
+	for i := range nodes {
+		b, err := pp.codec.Encode(nodes[i])
+		if err != nil {
+			return err
+		}
+		bs[i] = b
+	}
+
+	body, err := encodeBatch(pp.seq+1, deleted)
+	if err != nil {
+		return err
+	}
+
+	var written, heads []uint32
+	abort := func(err error) error {
+		pp.pending = append(pp.pending, written...)
+		pp.released = append(pp.released, heads...)
+
+		return err
+	}
+
+	seqs := make([]uint64, len(nodes))
+	for i := range nodes {
+		pages, seq, err := pp.writeRecord(pageTypeHead, pageFlagBatch, nodes[i].Key(), bs[i])
+		written = append(written, pages...)
+		if len(pages) > 0 {
+			heads = append(heads, pages[0])
+		}
+
+		if err != nil {
+			return abort(err)
+		}
+		seqs[i] = seq
+	}
+
+	// NOTE the batch record should not be stored before the nodes
+	if err := pp.f.Sync(); err != nil {
+		return abort(err)
+	}
+
+	batch, _, err := pp.writeRecord(pageTypeBatch, 0, nil, body)
+	if err != nil {
+		written = append(written, batch...)
+		if len(batch) > 0 {
+			heads = append(heads, batch[0])
+		}
+
+		return abort(err)
+	}
+
+	for i := range nodes {
+		key := string(nodes[i].Key())
+		if idx, found := pp.index[key]; found {
+			pp.release(idx.page)
+		}
+
+		pp.index[key] = pageNodePoolIndex{page: heads[i], seq: seqs[i]}
+	}
+
+	for _, key := range deleted {
+		if idx, found := pp.index[string(key)]; found {
+			pp.release(idx.page)
+			delete(pp.index, string(key))
+		}
+	}
+
+	pp.batches = append(pp.batches, batch...)
+
+	return nil
+}
+
+func (pp *PageNodePool) set(node Node) error {
+	if pp.f == nil {
+		return ClosedNodePoolError.Wrapf("key=%x", node.Key())
+	}
+
+	b, err := pp.codec.Encode(node)
+	if err != nil {
+		return err
+	}
+
+	return pp.write(node.Key(), b)
+}
+
+// write writes the encoded node to the pages.
+func (pp *PageNodePool) write(key, b []byte) error {
+	pages, seq, err := pp.writeRecord(pageTypeHead, 0, key, b)
+	if err != nil {
+		return err
+	}
+
+	if idx, found := pp.index[string(key)]; found {
+		pp.release(idx.page)
+	}
+
+	pp.index[string(key)] = pageNodePoolIndex{page: pages[0], seq: seq}
+
+	return nil
+}
+
+// writeRecord writes the record to the pages with new seq. The overflow pages
+// are written before the head page. The allocated pages are returned even if
+// it fails; the first one is the head page.
+func (pp *PageNodePool) writeRecord(typ, flags byte, key, b []byte) ([]uint32, uint64, error) {
+	capacity := pp.pageSize - pageHeaderSize
+	if len(key) > capacity-pageHeadHeaderSize || len(key) > 0xffff {
+		return nil, 0, InvalidNodeError.Wrapf(
+			"key too long for page; key length=%d page size=%d", len(key), pp.pageSize)
+	}
+
+	head := make([]byte, pageHeadHeaderSize+len(key))
+	pp.seq++
+	binary.BigEndian.PutUint64(head[:8], pp.seq)
+	binary.BigEndian.PutUint32(head[8:12], uint32(len(b)))
+	binary.BigEndian.PutUint16(head[12:14], uint16(len(key)))
+	copy(head[pageHeadHeaderSize:], key)
+
+	first := capacity - len(head)
+	if first > len(b) {
+		first = len(b)
+	}
+
+	var chunks [][]byte
+	for rest := b[first:]; len(rest) > 0; {
+		n := capacity
+		if n > len(rest) {
+			n = len(rest)
+		}
+		chunks = append(chunks, rest[:n])
+		rest = rest[n:]
+	}
+
+	pages := make([]uint32, len(chunks)+1)
+	for i := range pages {
+		pages[i] = pp.allocate()
+	}
+
+	for i := len(chunks) - 1; i >= 0; i-- {
+		var next uint32
+		if i < len(chunks)-1 {
+			next = pages[i+2]
+		}
+
+		if err := pp.writePage(pages[i+1], pageTypeOverflow, 0, next, chunks[i]); err != nil {
+			return pages, 0, err
+		}
+	}
+
+	var next uint32
+	if len(chunks) > 0 {
+		next = pages[1]
+	}
+
+	if err := pp.writePage(pages[0], typ, flags, next, append(head, b[:first]...)); err != nil {
+		return pages, 0, err
+	}
+
+	return pages, pp.seq, nil
+}
+
+func (pp *PageNodePool) writePage(page uint32, typ, flags byte, next uint32, data []byte) error {
+	b := make([]byte, pp.pageSize)
+	b[4] = typ
+	b[5] = flags
+	binary.BigEndian.PutUint32(b[8:12], next)
+	binary.BigEndian.PutUint32(b[12:16], uint32(len(data)))
+	copy(b[pageHeaderSize:], data)
+	binary.BigEndian.PutUint32(b[:4], crc32.ChecksumIEEE(b[4:pageHeaderSize+len(data)]))
+
+	_, err := pp.f.WriteAt(b, pp.offset(page))
+
+	return err
+}
+
+// allocate returns the free page; if no free page, new page is appended.
+func (pp *PageNodePool) allocate() uint32 {
+	if len(pp.free) > 0 {
+		page := pp.free[len(pp.free)-1]
+		pp.free = pp.free[:len(pp.free)-1]
+
+		return page
+	}
+
+	page := pp.pages
+	pp.pages++
+
+	return page
+}
+
+// release adds the pages of record to the pending free pages.
+func (pp *PageNodePool) release(head uint32) {
+	pp.released = append(pp.released, head)
+
+	b := make([]byte, pp.pageSize)
+	for p := head; p != 0 && p < pp.pages; {
+		pp.pending = append(pp.pending, p)
+
+		if _, err := pp.f.ReadAt(b, pp.offset(p)); err != nil {
+			break
+		}
+
+		h, err := pp.parsePage(b)
+		if err != nil {
+			break
+		}
+		p = h.next
+	}
+}
+
+// Delete removes node from the index. The head page of node is marked as free
+// by Commit() and then the pages of node can be reused.
+func (pp *PageNodePool) Delete(key []byte) error {
+	pp.Lock()
+	defer pp.Unlock()
+
+	if pp.f == nil {
+		return ClosedNodePoolError.Wrapf("key=%x", key)
+	}
+
+	idx, found := pp.index[string(key)]
+	if !found {
+		return nil
+	}
+
+	pp.release(idx.page)
+	delete(pp.index, string(key))
+
+	return nil
+}
+
+func (pp *PageNodePool) Has(key []byte) (bool, error) {
+	pp.Lock()
+	defer pp.Unlock()
+
+	_, found := pp.index[string(key)]
+
+	return found, nil
+}
+
+func (pp *PageNodePool) Len() (int, error) {
+	pp.Lock()
+	defer pp.Unlock()
+
+	return len(pp.index), nil
+}
+
+// Pages returns the number of pages and free pages. The pending free pages,
+// which are not committed, are also counted as free.
+func (pp *PageNodePool) Pages() (int, int) {
+	pp.Lock()
+	defer pp.Unlock()
+
+	return int(pp.pages), len(pp.free) + len(pp.pending)
+}
+
+// Traverse traverses the nodes, which are set before Traverse() is called.
+func (pp *PageNodePool) Traverse(f NodeTraverseFunc) error {
+	pp.Lock()
+	keys := make([][]byte, 0, len(pp.index))
+	for k := range pp.index {
+		keys = append(keys, []byte(k))
+	}
+	pp.Unlock()
+
+	for _, key := range keys {
+		node, err := pp.Get(key)
+		if err != nil {
+			return err
+		} else if node == nil { // NOTE removed while traversing
+			continue
+		}
+
+		if keep, err := f(node); err != nil {
+			return err
+		} else if !keep {
+			break
+		}
+	}
+
+	return nil
+}
+
+// Commit calls fsync. After fsync, the head pages of removed or overwritten
+// nodes are marked as free and their pages can be reused. The stable seq is
+// moved and the pages of batch records are also freed.
+func (pp *PageNodePool) Commit() error {
+	pp.Lock()
+	defer pp.Unlock()
+
+	return pp.commit()
+}
+
+func (pp *PageNodePool) commit() error {
+	if pp.f == nil {
+		return ClosedNodePoolError.Wrapf("failed to commit")
+	}
+
+	if err := pp.f.Sync(); err != nil {
+		return err
+	}
+
+	if len(pp.released) > 0 {
+		for _, page := range pp.released {
+			if err := pp.writePage(page, pageTypeFree, 0, 0, nil); err != nil {
+				return err
+			}
+		}
+
+		if err := pp.f.Sync(); err != nil {
+			return err
+		}
+
+		pp.released = nil
+	}
+
+	if len(pp.batches) > 0 {
+		if err := pp.writeStable(pp.seq); err != nil {
+			return err
+		}
+
+		pp.pending = append(pp.pending, pp.batches...)
+		pp.batches = nil
+	}
+
+	if len(pp.pending) > 0 {
+		pp.free = append(pp.free, pp.pending...)
+		pp.pending = nil
+
+		sort.Slice(pp.free, func(i, j int) bool { return pp.free[i] > pp.free[j] })
+	}
+
+	return nil
+}
+
+// Compact rewrites the file without free pages. The nodes are written in the
+// order of key.
+func (pp *PageNodePool) Compact() error {
+	pp.Lock()
+	defer pp.Unlock()
+
+	if err := pp.commit(); err != nil {
+		return err
+	}
+
+	tmp := pp.path + ".compact"
+	_ = os.Remove(tmp)
+
+	np, err := OpenPageNodePool(tmp, pp.codec, pp.pageSize)
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(pp.index))
+	for k := range pp.index {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		b, err := pp.read([]byte(k))
+		if err == nil {
+			err = np.write([]byte(k), b)
+		}
+
+		if err != nil {
+			_ = np.Close()
+			_ = os.Remove(tmp)
+
+			return err
+		}
+	}
+
+	if err := np.Close(); err != nil {
+		return err
+	}
+
+	before := pp.pages
+
+	if err := pp.f.Close(); err != nil {
+		return err
+	}
+	pp.f = nil
+
+	if err := os.Rename(tmp, pp.path); err != nil {
+		return err
+	}
+
+	if err := pp.open(); err != nil {
+		return err
+	}
+
+	pp.Log().Debug().Uint32("before", before).Uint32("after", pp.pages).Msg("compacted")
+
+	return nil
+}
+
+// Close commits and closes the file.
+func (pp *PageNodePool) Close() error {
+	pp.Lock()
+	defer pp.Unlock()
+
+	if pp.f == nil {
+		return nil
+	}
+
+	if err := pp.commit(); err != nil {
+		return err
+	}
+
+	err := pp.f.Close()
+	pp.f = nil
+
+	return err
+}
@@ -0,0 +1,358 @@
+package avl
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/xerrors"
+)
+
+type testPageNodePool struct {
+	suite.Suite
+	dir string
+}
+
+func (t *testPageNodePool) SetupTest() {
+	dir, err := ioutil.TempDir("", "avl-page-nodepool")
+	t.NoError(err)
+
+	t.dir = dir
+}
+
+func (t *testPageNodePool) TearDownTest() {
+	_ = os.RemoveAll(t.dir)
+}
+
+func (t *testPageNodePool) path() string {
+	return filepath.Join(t.dir, "nodes")
+}
+
+func (t *testPageNodePool) open() *PageNodePool {
+	pp, err := OpenPageNodePool(t.path(), NewBinaryNodeCodec(nil), minPageSize)
+	t.NoError(err)
+
+	return pp
+}
+
+// newNode returns node with payload, which spans the multiple pages.
+func (t *testPageNodePool) newNode(i, size int) BaseNode {
+	return NewBaseNode(nodeIntKey(i), 0, nil, nil, bytes.Repeat([]byte{byte(i)}, size))
+}
+
+func (t *testPageNodePool) TestReopen() {
+	pp := t.open()
+	tr, err := newExampleTreeInPool(pp, 50)
+	t.NoError(err)
+	t.NoError(pp.Close())
+
+	pp = t.open()
+	defer pp.Close()
+
+	n, err := pp.Len()
+	t.NoError(err)
+	t.Equal(50, n)
+
+	ptr, err := NewTree(tr.Root().Key(), pp)
+	t.NoError(err)
+	t.NoError(ptr.IsValid())
+
+	node, err := ptr.Get(nodeIntKey(33))
+	t.NoError(err)
+	t.Equal(nodeIntKey(33), node.Key())
+}
+
+func (t *testPageNodePool) TestSpanPages() {
+	pp := t.open()
+
+	for _, size := range []int{0, 10, 100, 1000} {
+		t.NoError(pp.Set(t.newNode(size, size)))
+	}
+	t.NoError(pp.Close())
+
+	pp = t.open()
+	defer pp.Close()
+
+	for _, size := range []int{0, 10, 100, 1000} {
+		node, err := pp.Get(nodeIntKey(size))
+		t.NoError(err)
+		t.True(bytes.Equal(t.newNode(size, size).Payload(), node.(BaseNode).Payload()), "size=%d", size)
+	}
+}
+
+func (t *testPageNodePool) TestReuse() {
+	pp := t.open()
+	defer pp.Close()
+
+	t.NoError(pp.Set(t.newNode(1, 1000)))
+	t.NoError(pp.Commit())
+
+	pages, free := pp.Pages()
+	t.Equal(0, free)
+
+	// NOTE overwritten node; old pages are not reused before commit
+	t.NoError(pp.Set(t.newNode(1, 1000)))
+
+	npages, free := pp.Pages()
+	t.Equal(pages*2-1, npages)
+	t.Equal(pages-1, free)
+
+	t.NoError(pp.Commit())
+
+	// NOTE the freed pages are reused
+	t.NoError(pp.Set(t.newNode(2, 1000)))
+
+	rpages, free := pp.Pages()
+	t.Equal(npages, rpages)
+	t.Equal(0, free)
+
+	t.NoError(pp.Delete(nodeIntKey(1)))
+	t.NoError(pp.Commit())
+
+	_, free = pp.Pages()
+	t.Equal(pages-1, free)
+
+	node, err := pp.Get(nodeIntKey(2))
+	t.NoError(err)
+	t.Equal(t.newNode(2, 1000), node)
+}
+
+func (t *testPageNodePool) TestDeleteReopen() {
+	pp := t.open()
+	t.NoError(pp.SetMany([]Node{t.newNode(1, 300), t.newNode(2, 300)}))
+	t.NoError(pp.Commit())
+
+	// NOTE overwrite and then delete; the old head page should not be revived
+	t.NoError(pp.Set(t.newNode(1, 10)))
+	t.NoError(pp.Delete(nodeIntKey(1)))
+	t.NoError(pp.Close())
+
+	pp = t.open()
+	defer pp.Close()
+
+	has, err := pp.Has(nodeIntKey(1))
+	t.NoError(err)
+	t.False(has)
+
+	node, err := pp.Get(nodeIntKey(2))
+	t.NoError(err)
+	t.Equal(t.newNode(2, 300), node)
+}
+
+func (t *testPageNodePool) TestCompact() {
+	pp := t.open()
+	defer pp.Close()
+
+	for i := 0; i < 20; i++ {
+		t.NoError(pp.Set(t.newNode(i, 300)))
+	}
+	for i := 0; i < 20; i += 2 {
+		t.NoError(pp.Delete(nodeIntKey(i)))
+	}
+	t.NoError(pp.Commit())
+
+	before, free := pp.Pages()
+	t.True(free > 0)
+
+	t.NoError(pp.Compact())
+
+	after, free := pp.Pages()
+	t.Equal(0, free)
+	t.True(after < before)
+
+	fi, err := os.Stat(t.path())
+	t.NoError(err)
+	t.Equal(int64(after*minPageSize), fi.Size())
+
+	n, err := pp.Len()
+	t.NoError(err)
+	t.Equal(10, n)
+
+	for i := 1; i < 20; i += 2 {
+		node, err := pp.Get(nodeIntKey(i))
+		t.NoError(err)
+		t.Equal(t.newNode(i, 300), node)
+	}
+}
+
+func (t *testPageNodePool) TestTornPage() {
+	pp := t.open()
+	t.NoError(pp.Set(t.newNode(1, 10)))
+	t.NoError(pp.Commit())
+	t.NoError(pp.Set(t.newNode(2, 1000)))
+	t.NoError(pp.Close())
+
+	b, err := ioutil.ReadFile(t.path())
+	t.NoError(err)
+
+	// NOTE torn the last overflow page of node 2
+	t.NoError(ioutil.WriteFile(t.path(), b[:len(b)-minPageSize/2], 0o600))
+
+	pp = t.open()
+	defer pp.Close()
+
+	node, err := pp.Get(nodeIntKey(1))
+	t.NoError(err)
+	t.Equal(t.newNode(1, 10), node)
+
+	_, err = pp.Get(nodeIntKey(2))
+	t.True(xerrors.Is(err, CorruptedFileError))
+}
+
+func (t *testPageNodePool) TestBrokenHeadPage() {
+	pp := t.open()
+	t.NoError(pp.Set(t.newNode(1, 10)))
+	t.NoError(pp.Commit())
+
+	// NOTE overwritten, but the new head page is broken by crash before commit
+	t.NoError(pp.Set(t.newNode(1, 20)))
+	idx := pp.index[string(nodeIntKey(1))]
+	t.NoError(pp.f.Close())
+
+	b, err := ioutil.ReadFile(t.path())
+	t.NoError(err)
+	b[int(idx.page)*minPageSize+pageHeaderSize] ^= 0xff
+	t.NoError(ioutil.WriteFile(t.path(), b, 0o600))
+
+	pp = t.open()
+	defer pp.Close()
+
+	node, err := pp.Get(nodeIntKey(1))
+	t.NoError(err)
+	t.Equal(t.newNode(1, 10), node)
+}
+
+func (t *testPageNodePool) TestSupersededHeadPage() {
+	pp := t.open()
+	t.NoError(pp.Set(t.newNode(1, 300)))
+	t.NoError(pp.Commit())
+
+	// NOTE overwritten and crashed before commit; the both head pages remain.
+	t.NoError(pp.Set(t.newNode(1, 20)))
+	t.NoError(pp.f.Close())
+
+	pp = t.open()
+
+	node, err := pp.Get(nodeIntKey(1))
+	t.NoError(err)
+	t.Equal(t.newNode(1, 20), node)
+
+	t.NoError(pp.Delete(nodeIntKey(1)))
+	t.NoError(pp.Commit())
+	t.NoError(pp.Close())
+
+	// NOTE the old record is not revived
+	pp = t.open()
+	defer pp.Close()
+
+	node, err = pp.Get(nodeIntKey(1))
+	t.NoError(err)
+	t.Nil(node)
+}
+
+func (t *testPageNodePool) TestApply() {
+	pp := t.open()
+	t.NoError(pp.SetMany([]Node{t.newNode(1, 10), t.newNode(2, 10)}))
+	t.NoError(pp.Commit())
+
+	t.NoError(pp.Apply([]Node{t.newNode(1, 300), t.newNode(3, 300)}, [][]byte{nodeIntKey(2)}))
+
+	// NOTE crashed before commit; the batch is recovered.
+	t.NoError(pp.f.Close())
+
+	pp = t.open()
+
+	nodes, err := pp.GetMany([][]byte{nodeIntKey(1), nodeIntKey(2), nodeIntKey(3)})
+	t.NoError(err)
+	t.Equal(t.newNode(1, 300), nodes[0])
+	t.Nil(nodes[1])
+	t.Equal(t.newNode(3, 300), nodes[2])
+	t.True(pp.stable >= pp.seq)
+
+	t.NoError(pp.Apply([]Node{t.newNode(4, 10)}, nil))
+	t.Equal(1, len(pp.batches))
+	t.NoError(pp.Close())
+	t.Nil(pp.batches)
+
+	pp = t.open()
+	defer pp.Close()
+
+	n, err := pp.Len()
+	t.NoError(err)
+	t.Equal(3, n)
+
+	node, err := pp.Get(nodeIntKey(4))
+	t.NoError(err)
+	t.Equal(t.newNode(4, 10), node)
+}
+
+func (t *testPageNodePool) TestApplyWithoutBatch() {
+	pp := t.open()
+	t.NoError(pp.SetMany([]Node{t.newNode(1, 10), t.newNode(2, 10)}))
+	t.NoError(pp.Commit())
+
+	t.NoError(pp.Apply([]Node{t.newNode(1, 300), t.newNode(3, 300)}, [][]byte{nodeIntKey(2)}))
+
+	// NOTE crashed before the batch record is stored
+	for _, page := range pp.batches {
+		t.NoError(pp.writePage(page, pageTypeFree, 0, 0, nil))
+	}
+	t.NoError(pp.f.Close())
+
+	pp = t.open()
+
+	nodes, err := pp.GetMany([][]byte{nodeIntKey(1), nodeIntKey(2), nodeIntKey(3)})
+	t.NoError(err)
+	t.Equal(t.newNode(1, 10), nodes[0])
+	t.Equal(t.newNode(2, 10), nodes[1])
+	t.Nil(nodes[2])
+
+	// NOTE the head pages of incomplete batch are not revived
+	t.NoError(pp.Set(t.newNode(5, 10)))
+	t.NoError(pp.Apply(nil, nil))
+	t.NoError(pp.Close())
+
+	pp = t.open()
+	defer pp.Close()
+
+	node, err := pp.Get(nodeIntKey(3))
+	t.NoError(err)
+	t.Nil(node)
+
+	n, err := pp.Len()
+	t.NoError(err)
+	t.Equal(3, n)
+}
+
+func (t *testPageNodePool) TestInvalidPageSizeInMeta() {
+	pp := t.open()
+	t.NoError(pp.Set(t.newNode(1, 10)))
+	t.NoError(pp.Close())
+
+	b, err := ioutil.ReadFile(t.path())
+	t.NoError(err)
+
+	for _, size := range []uint32{0, minPageSize - 1, maxPageSize + 1} {
+		binary.BigEndian.PutUint32(b[len(pageNodePoolMeta):], size)
+		t.NoError(ioutil.WriteFile(t.path(), b, 0o600))
+
+		_, err = OpenPageNodePool(t.path(), NewBinaryNodeCodec(nil), minPageSize)
+		t.True(xerrors.Is(err, CorruptedFileError), "size=%d", size)
+	}
+}
+
+func (t *testPageNodePool) TestKeyTooLong() {
+	pp := t.open()
+	defer pp.Close()
+
+	err := pp.Set(NewBaseNode(bytes.Repeat([]byte{1}, minPageSize), 0, nil, nil, nil))
+	t.True(xerrors.Is(err, InvalidNodeError))
+}
+
+func TestPageNodePool(t *testing.T) {
+	suite.Run(t, new(testPageNodePool))
+}
@@ -0,0 +1,179 @@
+package avl
+
+var (
+	ReadOnlyNodePoolError = NewWrapError("read-only NodePool")
+)
+
+// ReadOnlyNodePool is the read-only view of NodePool. Set(), SetMany() and
+// Delete() return ReadOnlyNodePoolError and the inner NodePool is not exposed,
+// so the holder of ReadOnlyNodePool can not change the inner NodePool.
+//
+// The returned nodes are the read-only views, ReadOnlyNode, which expose only
+// the Node methods, the payload and the hash methods if the node has them, so
+// the shared MutableNode of the inner NodePool can not be changed by type
+// assertion. The returned bytes are the copies.
+type ReadOnlyNodePool struct {
+	np NodePool
+}
+
+// NewReadOnlyNodePool returns the read-only view of NodePool. If np is
+// already ReadOnlyNodePool, it's returned.
+func NewReadOnlyNodePool(np NodePool) *ReadOnlyNodePool {
+	if rp, ok := np.(*ReadOnlyNodePool); ok {
+		return rp
+	}
+
+	return &ReadOnlyNodePool{np: np}
+}
+
+func (rp *ReadOnlyNodePool) Get(key []byte) (Node, error) {
+	node, err := rp.np.Get(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return newReadOnlyNode(node), nil
+}
+
+func (rp *ReadOnlyNodePool) GetMany(keys [][]byte) ([]Node, error) {
+	nodes, err := GetNodes(rp.np, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range nodes {
+		nodes[i] = newReadOnlyNode(nodes[i])
+	}
+
+	return nodes, nil
+}
+
+// Set returns ReadOnlyNodePoolError.
+func (rp *ReadOnlyNodePool) Set(node Node) error {
+	return ReadOnlyNodePoolError.Wrapf("key=%x", node.Key())
+}
+
+// SetMany returns ReadOnlyNodePoolError.
+func (rp *ReadOnlyNodePool) SetMany([]Node) error {
+	return ReadOnlyNodePoolError.Wrapf("failed to set nodes")
+}
+
+// Delete returns ReadOnlyNodePoolError.
+func (rp *ReadOnlyNodePool) Delete(key []byte) error {
+	return ReadOnlyNodePoolError.Wrapf("key=%x", key)
+}
+
+func (rp *ReadOnlyNodePool) Has(key []byte) (bool, error) {
+	return HasNode(rp.np, key)
+}
+
+func (rp *ReadOnlyNodePool) Len() (int, error) {
+	return CountNodes(rp.np)
+}
+
+func (rp *ReadOnlyNodePool) Traverse(f NodeTraverseFunc) error {
+	return rp.np.Traverse(func(node Node) (bool, error) {
+		return f(newReadOnlyNode(node))
+	})
+}
+
+// ReadOnlyNode is the node from ReadOnlyNodePool. Payload() returns the copy
+// of the payload of node, if the node has Payload() []byte, like BaseNode;
+// otherwise it's nil. The user's node can expose it's value to the read-only
+// view by Payload().
+type ReadOnlyNode interface {
+	Node
+	Payload() []byte
+}
+
+type payloadNode interface {
+	Payload() []byte
+}
+
+// hashNode is the node with hashes, like hashable.HashableNode.
+type hashNode interface {
+	Node
+	Hash() []byte
+	LeftHash() []byte
+	RightHash() []byte
+	ValueHash() []byte
+}
+
+// readOnlyNode exposes only the Node methods and the payload of the inner
+// node.
+type readOnlyNode struct {
+	n Node
+}
+
+// readOnlyHashNode exposes only the Node, payload and hash methods of the
+// inner node.
+type readOnlyHashNode struct {
+	readOnlyNode
+}
+
+// newReadOnlyNode returns the read-only view of node. If node has the hash
+// methods, the view also has them.
+func newReadOnlyNode(node Node) Node {
+	switch t := node.(type) {
+	case nil:
+		return nil
+	case readOnlyNode, readOnlyHashNode:
+		return node
+	case hashNode:
+		return readOnlyHashNode{readOnlyNode: readOnlyNode{n: t}}
+	default:
+		return readOnlyNode{n: node}
+	}
+}
+
+func (rn readOnlyNode) Key() []byte {
+	return copyBytes(rn.n.Key())
+}
+
+func (rn readOnlyNode) Height() int16 {
+	return rn.n.Height()
+}
+
+func (rn readOnlyNode) LeftKey() []byte {
+	return copyBytes(rn.n.LeftKey())
+}
+
+func (rn readOnlyNode) RightKey() []byte {
+	return copyBytes(rn.n.RightKey())
+}
+
+func (rn readOnlyNode) Payload() []byte {
+	if pn, ok := rn.n.(payloadNode); ok {
+		return copyBytes(pn.Payload())
+	}
+
+	return nil
+}
+
+func (rn readOnlyHashNode) Hash() []byte {
+	return copyBytes(rn.n.(hashNode).Hash())
+}
+
+func (rn readOnlyHashNode) LeftHash() []byte {
+	return copyBytes(rn.n.(hashNode).LeftHash())
+}
+
+func (rn readOnlyHashNode) RightHash() []byte {
+	return copyBytes(rn.n.(hashNode).RightHash())
+}
+
+func (rn readOnlyHashNode) ValueHash() []byte {
+	return copyBytes(rn.n.(hashNode).ValueHash())
+}
+
+// copyBytes returns the copy of b; nil is kept as nil.
+func copyBytes(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+
+	c := make([]byte, len(b))
+	copy(c, b)
+
+	return c
+}
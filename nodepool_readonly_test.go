@@ -0,0 +1,168 @@
+package avl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/xerrors"
+)
+
+type testReadOnlyNodePool struct {
+	suite.Suite
+}
+
+func (t *testReadOnlyNodePool) TestWrite() {
+	inner := NewMapNodePool(nil)
+	t.NoError(inner.Set(newExampleNode(1)))
+
+	rp := NewReadOnlyNodePool(inner)
+
+	err := rp.Set(newExampleNode(2))
+	t.True(xerrors.Is(err, ReadOnlyNodePoolError))
+
+	err = rp.SetMany([]Node{newExampleNode(2)})
+	t.True(xerrors.Is(err, ReadOnlyNodePoolError))
+
+	err = rp.Delete(nodeIntKey(1))
+	t.True(xerrors.Is(err, ReadOnlyNodePoolError))
+
+	n, err := inner.Len()
+	t.NoError(err)
+	t.Equal(1, n)
+
+	// NOTE ReadOnlyNodePool is not wrapped again
+	t.True(rp == NewReadOnlyNodePool(rp))
+}
+
+func (t *testReadOnlyNodePool) TestRead() {
+	inner := NewMapNodePool(nil)
+	for i := 0; i < 3; i++ {
+		t.NoError(inner.Set(newExampleNode(i)))
+	}
+
+	rp := NewReadOnlyNodePool(inner)
+
+	node, err := rp.Get(nodeIntKey(1))
+	t.NoError(err)
+	t.Equal(nodeIntKey(1), node.Key())
+
+	nodes, err := rp.GetMany([][]byte{nodeIntKey(2), nodeIntKey(5)})
+	t.NoError(err)
+	t.Equal(nodeIntKey(2), nodes[0].Key())
+	t.Nil(nodes[1])
+
+	has, err := rp.Has(nodeIntKey(0))
+	t.NoError(err)
+	t.True(has)
+
+	n, err := rp.Len()
+	t.NoError(err)
+	t.Equal(3, n)
+}
+
+func (t *testReadOnlyNodePool) TestTree() {
+	tr, err := newExampleTree(20)
+	t.NoError(err)
+
+	rtr := tr.ReadOnly()
+	t.Equal(tr.Root().Key(), rtr.Root().Key())
+	t.NoError(rtr.IsValid())
+
+	node, err := rtr.Get(nodeIntKey(7))
+	t.NoError(err)
+	t.Equal(nodeIntKey(7), node.Key())
+
+	np := rtr.NodePool()
+	_, ok := np.(*ReadOnlyNodePool)
+	t.True(ok)
+
+	err = np.Set(newExampleMutableNode(100))
+	t.True(xerrors.Is(err, ReadOnlyNodePoolError))
+
+	// NOTE the original tree is not affected
+	t.NoError(tr.NodePool().Set(newExampleMutableNode(100)))
+
+	_, err = NewGarbageCollector(np).Collect(rtr.Root().Key())
+	t.True(xerrors.Is(err, ReadOnlyNodePoolError))
+
+	has, err := np.(*ReadOnlyNodePool).Has(nodeIntKey(100))
+	t.NoError(err)
+	t.True(has)
+}
+
+func (t *testReadOnlyNodePool) TestImmutableNode() {
+	tr, err := newExampleTree(20)
+	t.NoError(err)
+
+	rtr := tr.ReadOnly()
+
+	_, ok := rtr.Root().(MutableNode)
+	t.False(ok)
+
+	node, err := rtr.Get(nodeIntKey(7))
+	t.NoError(err)
+	_, ok = node.(MutableNode)
+	t.False(ok)
+
+	nodes, err := rtr.NodePool().(*ReadOnlyNodePool).GetMany([][]byte{nodeIntKey(3), nodeIntKey(30)})
+	t.NoError(err)
+	_, ok = nodes[0].(MutableNode)
+	t.False(ok)
+	t.Nil(nodes[1])
+
+	var n int
+	t.NoError(rtr.Traverse(func(node Node) (bool, error) {
+		_, ok := node.(MutableNode)
+		t.False(ok)
+		n++
+
+		return true, nil
+	}))
+	t.Equal(20, n)
+
+	t.NoError(rtr.NodePool().Traverse(func(node Node) (bool, error) {
+		_, ok := node.(MutableNode)
+		t.False(ok)
+
+		return true, nil
+	}))
+
+	// NOTE the returned keys are the copies
+	rtr.Root().Key()[0] = 'z'
+	rtr.Root().LeftKey()[0] = 'z'
+	rtr.Root().RightKey()[0] = 'z'
+	node.Key()[0] = 'z'
+
+	t.NoError(tr.IsValid())
+	t.NoError(rtr.IsValid())
+}
+
+func (t *testReadOnlyNodePool) TestPayload() {
+	inner := NewMapNodePool(nil)
+	t.NoError(inner.Set(NewBaseNode(nodeIntKey(1), 0, nil, nil, []byte("showme"))))
+	t.NoError(inner.Set(newExampleNode(2)))
+
+	rp := NewReadOnlyNodePool(inner)
+
+	node, err := rp.Get(nodeIntKey(1))
+	t.NoError(err)
+
+	rn, ok := node.(ReadOnlyNode)
+	t.True(ok)
+	t.Equal([]byte("showme"), rn.Payload())
+
+	rn.Payload()[0] = 'z'
+	t.Equal([]byte("showme"), rn.Payload())
+
+	_, ok = node.(BaseNode)
+	t.False(ok)
+
+	// NOTE node without Payload()
+	node, err = rp.Get(nodeIntKey(2))
+	t.NoError(err)
+	t.Nil(node.(ReadOnlyNode).Payload())
+}
+
+func TestReadOnlyNodePool(t *testing.T) {
+	suite.Run(t, new(testReadOnlyNodePool))
+}
@@ -0,0 +1,99 @@
+package avl
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type testNodePool struct {
+	suite.Suite
+}
+
+func (t *testNodePool) pools() map[string]NodePool {
+	return map[string]NodePool{
+		"SyncMapNodePool":    NewSyncMapNodePool(&sync.Map{}),
+		"MapNodePool":        NewMapNodePool(nil),
+		"MapMutableNodePool": NewMapMutableNodePool(nil),
+		"NodePool":           struct{ NodePool }{NewMapNodePool(nil)}, // NOTE only NodePool
+	}
+}
+
+func (t *testNodePool) TestCapabilities() {
+	for name, np := range t.pools() {
+		if name == "NodePool" {
+			continue
+		}
+
+		_, ok := np.(DeletableNodePool)
+		t.True(ok, name)
+		_, ok = np.(BatchNodePool)
+		t.True(ok, name)
+		_, ok = np.(CountableNodePool)
+		t.True(ok, name)
+	}
+}
+
+func (t *testNodePool) TestBatch() {
+	for name, np := range t.pools() {
+		nodes := []Node{newExampleMutableNode(10), newExampleMutableNode(20), newExampleMutableNode(30)}
+		t.NoError(SetNodes(np, nodes), name)
+
+		found, err := GetNodes(np, [][]byte{nodeIntKey(30), nil, nodeIntKey(40), nodeIntKey(10)})
+		t.NoError(err, name)
+		t.Equal(4, len(found), name)
+		t.Equal(nodeIntKey(30), found[0].Key(), name)
+		t.Nil(found[1], name)
+		t.Nil(found[2], name)
+		t.Equal(nodeIntKey(10), found[3].Key(), name)
+
+		n, err := CountNodes(np)
+		t.NoError(err, name)
+		t.Equal(3, n, name)
+
+		has, err := HasNode(np, nodeIntKey(20))
+		t.NoError(err, name)
+		t.True(has, name)
+
+		has, err = HasNode(np, nodeIntKey(40))
+		t.NoError(err, name)
+		t.False(has, name)
+	}
+}
+
+func (t *testNodePool) TestDelete() {
+	for name, np := range t.pools() {
+		dp, ok := np.(DeletableNodePool)
+		if !ok {
+			continue
+		}
+
+		t.NoError(SetNodes(np, []Node{newExampleMutableNode(10), newExampleMutableNode(20)}), name)
+		t.NoError(dp.Delete(nodeIntKey(10)), name)
+		t.NoError(dp.Delete(nodeIntKey(40)), name) // NOTE unknown key
+
+		has, err := HasNode(np, nodeIntKey(10))
+		t.NoError(err, name)
+		t.False(has, name)
+
+		n, err := CountNodes(np)
+		t.NoError(err, name)
+		t.Equal(1, n, name)
+	}
+}
+
+func (t *testNodePool) TestSetManyNotMutable() {
+	np := NewMapMutableNodePool(nil)
+
+	err := np.SetMany([]Node{newExampleMutableNode(10), newExampleNode(20)})
+	t.Error(err)
+
+	n, err := np.Len()
+	t.NoError(err)
+	t.Equal(0, n)
+}
+
+func TestNodePool(t *testing.T) {
+	suite.Run(t, new(testNodePool))
+}
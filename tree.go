@@ -6,6 +6,8 @@ import (
 
 var (
 	NodeNotFoundInPoolError = NewWrapError("node not found in pool")
+	CyclicNodeError         = NewWrapError("leaf refers it's ancestor")
+	SharedNodeError         = NewWrapError("node is shared by parents")
 )
 
 // NodeTraverseFunc is used for Tree.Traverse(). If keep is false, traversing
@@ -46,6 +48,18 @@ func (tr *Tree) NodePool() NodePool {
 	return tr.nodePool
 }
 
+// ReadOnly returns new Tree with the same root, which has the
+// ReadOnlyNodePool of NodePool. The returned Tree can be passed to the
+// untrusted code; it can not change NodePool through Tree.NodePool() and the
+// root and the nodes from it are the read-only views of nodes.
+func (tr *Tree) ReadOnly() *Tree {
+	return &Tree{
+		Logger:   tr.Logger,
+		nodePool: NewReadOnlyNodePool(tr.nodePool),
+		root:     newReadOnlyNode(tr.root),
+	}
+}
+
 // Root returns root node.
 func (tr *Tree) Root() Node {
 	return tr.root
@@ -65,6 +79,31 @@ func (tr *Tree) getLeaf(node Node, isLeft bool) (Node, error) {
 	return tr.nodePool.Get(key)
 }
 
+// getLeaves returns the left and right leaf of node. If NodePool is
+// BatchNodePool, the leaves are fetched at once.
+func (tr *Tree) getLeaves(node Node) (Node, Node, error) {
+	if node.LeftKey() == nil || node.RightKey() == nil {
+		left, err := tr.getLeaf(node, true)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		right, err := tr.getLeaf(node, false)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return left, right, nil
+	}
+
+	leaves, err := GetNodes(tr.nodePool, [][]byte{node.LeftKey(), node.RightKey()})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return leaves[0], leaves[1], nil
+}
+
 // Get finds and returns node by key. It traverse the entire tree.  Unlike
 // NodePool.Get() the only organized(not orphan) node will be returned.  For
 // performance, NodePool.Get() will be better.
@@ -102,6 +141,20 @@ func (tr *Tree) Get(key []byte) (Node, error) {
 
 // GetWithParents returns node with it's parents node.
 func (tr *Tree) GetWithParents(key []byte) (Node, []Node, error) {
+	node, parents, err := tr.Lookup(key)
+	if err != nil {
+		return nil, nil, err
+	} else if node == nil {
+		return nil, nil, nil
+	}
+
+	return node, parents, nil
+}
+
+// Lookup acts like GetWithParents(), but the visited nodes are returned even if
+// node is not found. When node is not found, the last visited node is the
+// node, which does not have the leaf for the key.
+func (tr *Tree) Lookup(key []byte) (Node, []Node, error) {
 	logs := tr.Log().With().Bytes("key", key).Logger()
 
 	if tr.root == nil {
@@ -132,49 +185,53 @@ func (tr *Tree) GetWithParents(key []byte) (Node, []Node, error) {
 		depth++
 	}
 
-	return nil, nil, nil
+	return nil, parents, nil
 }
 
 // Traverse traverses the entire tree. The error of NodeTraverseFunc mainly
-// error is from the external storage or other system.
+// error is from the external storage or other system. If the leaf refers it's
+// ancestor, Traverse stops with CyclicNodeError. Traverse remembers only the
+// nodes of the current path, so the node, which is reachable from the multiple
+// parents, is not detected; use TreeValidator for it.
 func (tr *Tree) Traverse(f NodeTraverseFunc) error {
 	if tr.root == nil {
 		return nil
 	}
 
-	_, err := tr.traverse(tr.root, f)
+	_, err := tr.traverse(tr.root, f, newPathNodeVisitor())
 	return err
 }
 
-func (tr *Tree) traverse(node Node, f NodeTraverseFunc) (bool, error) {
+func (tr *Tree) traverse(node Node, f NodeTraverseFunc, visitor *nodeVisitor) (bool, error) {
 	if node == nil {
 		return true, nil
 	}
 
+	if err := visitor.enter(node); err != nil {
+		return false, err
+	}
+	defer visitor.exit(node)
+
 	if keep, err := f(node); err != nil {
 		return false, err
 	} else if !keep {
 		return true, nil
 	}
 
-	var left, right Node
-	var err error
-	if left, err = tr.getLeaf(node, true); err != nil {
-		return false, err
-	}
-	if right, err = tr.getLeaf(node, false); err != nil {
+	left, right, err := tr.getLeaves(node)
+	if err != nil {
 		return false, err
 	}
 
 	if left != nil {
-		if keep, err := tr.traverse(left, f); err != nil {
+		if keep, err := tr.traverse(left, f, visitor); err != nil {
 			return false, err
 		} else if !keep {
 			return true, nil
 		}
 	}
 	if right != nil {
-		if keep, err := tr.traverse(right, f); err != nil {
+		if keep, err := tr.traverse(right, f, visitor); err != nil {
 			return false, err
 		} else if !keep {
 			return true, nil
@@ -188,3 +245,66 @@ func (tr *Tree) traverse(node Node, f NodeTraverseFunc) (bool, error) {
 func (tr *Tree) IsValid() error {
 	return NewTreeValidator(tr).IsValid()
 }
+
+// nodeVisitor guards the recursion over NodePool. It remembers the nodes in
+// the current path from root and, if visited is not nil, the visited nodes.
+type nodeVisitor struct {
+	visited map[string]struct{}
+	path    map[string]struct{}
+}
+
+// newNodeVisitor returns nodeVisitor, which detects the cyclic and shared
+// nodes. It remembers the all visited nodes.
+func newNodeVisitor() *nodeVisitor {
+	return &nodeVisitor{
+		visited: map[string]struct{}{},
+		path:    map[string]struct{}{},
+	}
+}
+
+// newPathNodeVisitor returns nodeVisitor, which detects only the cyclic nodes.
+// It remembers only the current path, so it's memory is bounded by the height
+// of tree.
+func newPathNodeVisitor() *nodeVisitor {
+	return &nodeVisitor{
+		path: map[string]struct{}{},
+	}
+}
+
+// check checks the node of key can be visited.
+func (nv *nodeVisitor) check(key []byte) error {
+	if _, found := nv.path[string(key)]; found {
+		return CyclicNodeError.Wrapf("key=%x", key)
+	} else if nv.visited == nil {
+		return nil
+	} else if _, found := nv.visited[string(key)]; found {
+		return SharedNodeError.Wrapf("key=%x", key)
+	}
+
+	return nil
+}
+
+// isVisited checks the node of key was visited.
+func (nv *nodeVisitor) isVisited(key []byte) bool {
+	_, found := nv.visited[string(key)]
+
+	return found
+}
+
+// enter marks the node is visited.
+func (nv *nodeVisitor) enter(node Node) error {
+	if err := nv.check(node.Key()); err != nil {
+		return err
+	}
+
+	if nv.visited != nil {
+		nv.visited[string(node.Key())] = struct{}{}
+	}
+	nv.path[string(node.Key())] = struct{}{}
+
+	return nil
+}
+
+func (nv *nodeVisitor) exit(node Node) {
+	delete(nv.path, string(node.Key()))
+}
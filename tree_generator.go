@@ -26,6 +26,32 @@ func NewTreeGenerator() *TreeGenerator {
 	}
 }
 
+// NewTreeGeneratorWithRoot returns new TreeGenerator, which starts from the
+// existing root. The nodes under root are collected by MutableNode.Left() and
+// MutableNode.Right().
+func NewTreeGeneratorWithRoot(root MutableNode) *TreeGenerator {
+	tg := NewTreeGenerator()
+	if root == nil {
+		return tg
+	}
+
+	tg.root = root
+
+	var collect func(MutableNode)
+	collect = func(node MutableNode) {
+		tg.nodes[string(node.Key())] = node
+
+		for _, leaf := range []MutableNode{node.Left(), node.Right()} {
+			if leaf != nil {
+				collect(leaf)
+			}
+		}
+	}
+	collect(root)
+
+	return tg
+}
+
 // Root returns root node of tree.
 func (tg *TreeGenerator) Root() MutableNode {
 	return tg.root
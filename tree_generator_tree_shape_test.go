@@ -361,6 +361,27 @@ func (t *testTreeGeneratorShape) Test() {
 	}
 }
 
+func (t *testTreeGeneratorShape) TestWithRoot() {
+	tg := NewTreeGenerator()
+	for i := 0; i < 10; i++ {
+		_, err := tg.Add(newExampleMutableNode(i))
+		t.NoError(err)
+	}
+
+	ntg := NewTreeGeneratorWithRoot(tg.Root())
+	t.Equal(len(tg.Nodes()), len(ntg.Nodes()))
+
+	for i := 10; i < 30; i++ {
+		_, err := ntg.Add(newExampleMutableNode(i))
+		t.NoError(err)
+	}
+	t.Equal(30, len(ntg.Nodes()))
+
+	tree, err := ntg.Tree()
+	t.NoError(err)
+	t.NoError(tree.IsValid())
+}
+
 func TestTreeGeneratorShape(t *testing.T) {
 	suite.Run(t, new(testTreeGeneratorShape))
 }
@@ -10,6 +10,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/suite"
+	"golang.org/x/xerrors"
 )
 
 type testTree struct {
@@ -322,6 +323,10 @@ func (t *testTree) TestShape() {
 }
 
 func (t *testTree) treeFromShape(root int, shape map[int]shape) (*Tree, error) {
+	return treeFromShape(root, shape)
+}
+
+func treeFromShape(root int, shape map[int]shape) (*Tree, error) {
 	np := NewMapNodePool(nil)
 	for k, v := range shape {
 		node := newExampleNode(k)
@@ -407,6 +412,128 @@ func (t *testTree) TestGetWithParents() {
 	}
 }
 
+func (t *testTree) TestLookup() {
+	shape := map[int]shape{
+		100: {height: 3, left: 50, right: 150},
+		50:  {height: 1, left: 30, right: 70},
+		150: {height: 2, left: 130, right: 180},
+		30:  {height: 0},
+		70:  {height: 0},
+		130: {height: 0},
+		170: {height: 0},
+		180: {height: 1, left: 170, right: 200},
+		200: {height: 0},
+	}
+
+	cases := map[int][]int{
+		100: {},              // found
+		170: {100, 150, 180}, // found
+		10:  {100, 50, 30},
+		60:  {100, 50, 70},
+		140: {100, 150, 130},
+		175: {100, 150, 180, 170},
+		300: {100, 150, 180, 200},
+	}
+
+	tr, err := t.treeFromShape(100, shape)
+	t.NoError(err)
+
+	for k, ps := range cases {
+		key := nodeIntKey(k)
+		n, visited, err := tr.Lookup(key)
+		t.NoError(err)
+
+		if _, found := shape[k]; found {
+			t.NotNil(n)
+			t.Equal(key, n.Key())
+		} else {
+			t.Nil(n)
+		}
+
+		t.Equal(len(ps), len(visited), "visited length not equal: %v", k)
+		for i := 0; i < len(ps); i++ {
+			t.Equal(ps[i], parseNodeIntKey(visited[i].Key()))
+		}
+	}
+}
+
+func (t *testTree) TestNodeValidator() {
+	shape := map[int]shape{
+		100: {height: 2, left: 50, right: 150},
+		50:  {height: 1, left: 30, right: 70},
+		150: {height: 0},
+		30:  {height: 0},
+		70:  {height: 0},
+	}
+
+	tr, err := t.treeFromShape(100, shape)
+	t.NoError(err)
+
+	var called int
+	invalid := map[int]bool{30: true, 150: true}
+	err = NewTreeValidator(tr).AddNodeValidator(func(node, left, right Node) error {
+		called++
+		if invalid[parseNodeIntKey(node.Key())] {
+			return xerrors.Errorf("findme")
+		}
+
+		return nil
+	}).IsValid()
+	t.True(xerrors.Is(err, InvalidTreeError))
+	t.Equal(len(shape), called)
+
+	var ne NodeValidatorError
+	t.True(xerrors.As(err, &ne))
+	t.Equal(len(invalid), len(ne.Keys))
+	for _, k := range ne.Keys {
+		t.True(invalid[parseNodeIntKey(k)])
+	}
+}
+
+func (t *testTree) TestTraverseCyclic() {
+	tr, err := t.treeFromShape(100, map[int]shape{
+		100: {height: 2, left: 50},
+		50:  {height: 1, left: 30},
+		30:  {height: 0, left: 100},
+	})
+	t.NoError(err)
+
+	err = tr.Traverse(func(Node) (bool, error) {
+		return true, nil
+	})
+	t.True(xerrors.Is(err, CyclicNodeError))
+
+	err = PrintDotGraph(tr, ioutil.Discard)
+	t.True(xerrors.Is(err, CyclicNodeError))
+}
+
+func (t *testTree) TestTraverseShared() {
+	tr, err := t.treeFromShape(100, map[int]shape{
+		100: {height: 2, left: 50, right: 150},
+		50:  {height: 1, right: 70},
+		150: {height: 1, left: 70},
+		70:  {height: 0},
+	})
+	t.NoError(err)
+
+	// NOTE Traverse and PrintDotGraph do not detect the shared node; the
+	// shared node is visited from each parent.
+	var visited int
+	err = tr.Traverse(func(node Node) (bool, error) {
+		if parseNodeIntKey(node.Key()) == 70 {
+			visited++
+		}
+
+		return true, nil
+	})
+	t.NoError(err)
+	t.Equal(2, visited)
+
+	t.NoError(PrintDotGraph(tr, ioutil.Discard))
+
+	t.True(xerrors.Is(tr.IsValid(), SharedNodeError))
+}
+
 func TestTree(t *testing.T) {
 	suite.Run(t, new(testTree))
 }
@@ -442,6 +569,37 @@ func newExampleMutableNode(i int) *ExampleMutableNode {
 	return &ExampleMutableNode{key: nodeIntKey(i)}
 }
 
+// newExampleTree returns the Tree of n ExampleMutableNodes.
+func newExampleTree(n int) (*Tree, error) {
+	tg := NewTreeGenerator()
+	for i := 0; i < n; i++ {
+		if _, err := tg.Add(newExampleMutableNode(i)); err != nil {
+			return nil, err
+		}
+	}
+
+	return tg.Tree()
+}
+
+// newExampleTreeInPool stores n ExampleMutableNodes into np and returns the
+// Tree of np.
+func newExampleTreeInPool(np NodePool, n int) (*Tree, error) {
+	tg := NewTreeGenerator()
+	for i := 0; i < n; i++ {
+		if _, err := tg.Add(newExampleMutableNode(i)); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, node := range tg.Nodes() {
+		if err := np.Set(node); err != nil {
+			return nil, err
+		}
+	}
+
+	return NewTree(tg.Root().Key(), np)
+}
+
 var printCount int32 // nolint
 
 func printTree(tg *TreeGenerator, verbose bool) error { // nolint
@@ -454,7 +612,9 @@ func printTree(tg *TreeGenerator, verbose bool) error { // nolint
 		return err
 	}
 
-	PrintDotGraph(tr, &b)
+	if err := PrintDotGraph(tr, &b); err != nil {
+		return err
+	}
 
 	count := atomic.LoadInt32(&printCount)
 	_ = ioutil.WriteFile(
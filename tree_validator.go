@@ -1,15 +1,41 @@
 package avl
 
-import "github.com/rs/zerolog"
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog"
+)
 
 var (
 	InvalidTreeError = NewWrapError("invalid tree")
 )
 
+// NodeValidatorFunc is the additional validation of node. It is called with
+// the leaves of node after IsValidNode() passed.
+type NodeValidatorFunc func(node, left, right Node) error
+
+// NodeValidatorError has the all errors from NodeValidatorFunc with the keys of
+// invalid nodes.
+type NodeValidatorError struct {
+	Keys [][]byte
+	Errs []error
+}
+
+func (ne NodeValidatorError) Error() string {
+	s := make([]string, len(ne.Keys))
+	for i := range ne.Keys {
+		s[i] = fmt.Sprintf("key=%x: %v", ne.Keys[i], ne.Errs[i])
+	}
+
+	return fmt.Sprintf("%d invalid node(s) found; %s", len(ne.Keys), strings.Join(s, ", "))
+}
+
 // TreeValidator will validate Tree is formed properly.
 type TreeValidator struct {
 	*Logger
-	tr *Tree
+	tr             *Tree
+	nodeValidators []NodeValidatorFunc
 }
 
 // NewTreeValidator returns new TreeValidator.
@@ -22,16 +48,32 @@ func NewTreeValidator(tr *Tree) TreeValidator {
 	}
 }
 
+// AddNodeValidator adds NodeValidatorFunc. Unlike the basic validation, the
+// errors from NodeValidatorFunc do not stop the validation; all the invalid
+// nodes are reported by NodeValidatorError.
+func (tv TreeValidator) AddNodeValidator(f ...NodeValidatorFunc) TreeValidator {
+	validators := make([]NodeValidatorFunc, len(tv.nodeValidators))
+	copy(validators, tv.nodeValidators)
+	tv.nodeValidators = append(validators, f...)
+
+	return tv
+}
+
 // IsValid checks whether tree is valid or not.
 func (tv TreeValidator) IsValid() error {
 	if tv.tr.Root() == nil {
 		return nil
 	}
 
-	if err := tv.validate(tv.tr.Root(), nil); err != nil {
+	var ne NodeValidatorError
+	if err := tv.validate(tv.tr.Root(), nil, keyBound{}, newNodeVisitor(), &ne); err != nil {
 		return err
 	}
 
+	if len(ne.Keys) > 0 {
+		return InvalidTreeError.Wrap(ne)
+	}
+
 	// check orphans
 	if found, err := tv.hasOrphans(); err != nil {
 		return err
@@ -43,34 +85,47 @@ func (tv TreeValidator) IsValid() error {
 }
 
 func (tv TreeValidator) hasOrphans() (bool, error) {
-	var found bool
-	err := tv.tr.NodePool().Traverse(func(node Node) (bool, error) {
-		n, err := tv.tr.Get(node.Key())
-		if err != nil {
-			return false, err
-		} else if n == nil {
-			log.Debug().Bytes("key", node.Key()).Msg("orphan found")
-			found = true
-			return false, nil
-		}
+	orphans, err := NewGarbageCollector(tv.tr.NodePool()).orphans(tv.tr.Root())
+	if err != nil {
+		return false, err
+	}
 
-		return true, nil
-	})
+	for _, key := range orphans {
+		log.Debug().Bytes("key", key).Msg("orphan found")
+	}
 
-	return found, err
+	return len(orphans) > 0, nil
 }
 
-func (tv TreeValidator) validate(node Node, parents []Node) error {
+func (tv TreeValidator) validate(
+	node Node, parents []Node, bound keyBound, visitor *nodeVisitor, ne *NodeValidatorError,
+) error {
 	logs := tv.Log().With().Int("parents", len(parents)).Bytes("key", node.Key()).Logger()
 
-	np := tv.tr.NodePool()
+	if err := visitor.enter(node); err != nil {
+		logs.Error().Err(err).Msg("invalid node found")
+		return err
+	}
+	defer visitor.exit(node)
 
-	var left, right Node
-	var err error
-	if left, err = np.Get(node.LeftKey()); err != nil {
+	if _, err := bound.check(node); err != nil {
+		logs.Error().Err(err).Msg("invalid node found")
 		return err
 	}
-	if right, err = np.Get(node.RightKey()); err != nil {
+
+	for _, key := range [][]byte{node.LeftKey(), node.RightKey()} {
+		if key == nil {
+			continue
+		}
+
+		if err := visitor.check(key); err != nil {
+			logs.Error().Err(err).Msg("invalid node found")
+			return err
+		}
+	}
+
+	left, right, err := tv.tr.getLeaves(node)
+	if err != nil {
 		return err
 	}
 
@@ -79,13 +134,21 @@ func (tv TreeValidator) validate(node Node, parents []Node) error {
 		return err
 	}
 
+	for _, f := range tv.nodeValidators {
+		if err := f(node, left, right); err != nil {
+			logs.Error().Err(err).Msg("invalid node found by node validator")
+			ne.Keys = append(ne.Keys, node.Key())
+			ne.Errs = append(ne.Errs, err)
+		}
+	}
+
 	if left != nil {
-		if err := tv.validate(left, append(parents, node)); err != nil {
+		if err := tv.validate(left, append(parents, node), bound.leaf(node, true), visitor, ne); err != nil {
 			return err
 		}
 	}
 	if right != nil {
-		if err := tv.validate(right, append(parents, node)); err != nil {
+		if err := tv.validate(right, append(parents, node), bound.leaf(node, false), visitor, ne); err != nil {
 			return err
 		}
 	}
@@ -94,3 +157,40 @@ func (tv TreeValidator) validate(node Node, parents []Node) error {
 
 	return nil
 }
+
+// keyBound is the interval of key, which is implied by the ancestors. low and
+// high are the nearest ancestors, which the node is placed in the right and
+// left of; nil means no bound.
+type keyBound struct {
+	low  Node
+	high Node
+}
+
+// check checks the key of node is in the interval. If not, it returns the
+// breached ancestor.
+func (kb keyBound) check(node Node) (Node, error) {
+	if kb.low != nil && CompareKey(node.Key(), kb.low.Key()) <= 0 {
+		return kb.low, InvalidNodeError.Wrapf(
+			"key must be greater than ancestor: key=%x <= ancestor=%x",
+			node.Key(), kb.low.Key(),
+		)
+	}
+
+	if kb.high != nil && CompareKey(node.Key(), kb.high.Key()) >= 0 {
+		return kb.high, InvalidNodeError.Wrapf(
+			"key must be lesser than ancestor: key=%x >= ancestor=%x",
+			node.Key(), kb.high.Key(),
+		)
+	}
+
+	return nil, nil
+}
+
+// leaf returns the keyBound of the left or right leaf of node.
+func (kb keyBound) leaf(node Node, isLeft bool) keyBound {
+	if isLeft {
+		return keyBound{low: kb.low, high: node}
+	}
+
+	return keyBound{low: node, high: kb.high}
+}
@@ -0,0 +1,223 @@
+package avl
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// ViolationKind is the kind of violation found by TreeValidator.Validate().
+type ViolationKind string
+
+const (
+	ViolationKeyOrder      ViolationKind = "key-order"
+	ViolationHeight        ViolationKind = "height"
+	ViolationBalance       ViolationKind = "balance"
+	ViolationMissingChild  ViolationKind = "missing-child"
+	ViolationOrphan        ViolationKind = "orphan"
+	ViolationCycle         ViolationKind = "cycle"
+	ViolationSharedNode    ViolationKind = "shared-node"
+	ViolationNodeValidator ViolationKind = "node-validator"
+)
+
+// Violation is the invalid node with the kind of violation. Path is the keys
+// from root to the parent of invalid node; the path of orphan is empty. If the
+// key of node is out of the interval, which the ancestors imply, Ancestor is
+// the key of breached ancestor.
+type Violation struct {
+	Kind     ViolationKind
+	Key      []byte
+	Path     [][]byte
+	Ancestor []byte
+	Err      error
+}
+
+func (vl Violation) String() string {
+	path := make([]string, len(vl.Path))
+	for i := range vl.Path {
+		path[i] = fmt.Sprintf("%x", vl.Path[i])
+	}
+
+	if vl.Ancestor != nil {
+		return fmt.Sprintf(
+			"kind=%s key=%x path=[%s] ancestor=%x: %v",
+			vl.Kind, vl.Key, strings.Join(path, " "), vl.Ancestor, vl.Err,
+		)
+	}
+
+	return fmt.Sprintf(
+		"kind=%s key=%x path=[%s]: %v",
+		vl.Kind, vl.Key, strings.Join(path, " "), vl.Err,
+	)
+}
+
+// ValidationReport has the all violations in Tree.
+type ValidationReport struct {
+	Violations []Violation
+}
+
+// IsValid returns true if no violation found.
+func (vr ValidationReport) IsValid() bool {
+	return len(vr.Violations) < 1
+}
+
+// Err returns InvalidTreeError with the all violations. If no violation found,
+// it returns nil.
+func (vr ValidationReport) Err() error {
+	if vr.IsValid() {
+		return nil
+	}
+
+	s := make([]string, len(vr.Violations))
+	for i := range vr.Violations {
+		s[i] = vr.Violations[i].String()
+	}
+
+	return InvalidTreeError.Wrapf("%d violation(s) found; %s", len(vr.Violations), strings.Join(s, ", "))
+}
+
+func (vr *ValidationReport) add(kind ViolationKind, node Node, parents []Node, err error) {
+	vr.addWithAncestor(kind, node, parents, nil, err)
+}
+
+func (vr *ValidationReport) addWithAncestor(kind ViolationKind, node Node, parents []Node, ancestor Node, err error) {
+	path := make([][]byte, len(parents))
+	for i := range parents {
+		path[i] = parents[i].Key()
+	}
+
+	var ancestorKey []byte
+	if ancestor != nil {
+		ancestorKey = ancestor.Key()
+	}
+
+	vr.Violations = append(vr.Violations, Violation{
+		Kind:     kind,
+		Key:      node.Key(),
+		Path:     path,
+		Ancestor: ancestorKey,
+		Err:      err,
+	})
+}
+
+// Validate walks the entire tree and reports every violation. Unlike
+// IsValid(), Validate does not stop at the first invalid node. The returned
+// error is only for the external storage error.
+func (tv TreeValidator) Validate() (ValidationReport, error) {
+	var report ValidationReport
+
+	if tv.tr.Root() == nil {
+		return report, nil
+	}
+
+	visitor := newNodeVisitor()
+	if err := tv.report(tv.tr.Root(), nil, keyBound{}, visitor, &report); err != nil {
+		return ValidationReport{}, err
+	}
+
+	// check orphans
+	if err := tv.tr.NodePool().Traverse(func(node Node) (bool, error) {
+		if !visitor.isVisited(node.Key()) {
+			report.add(ViolationOrphan, node, nil, InvalidTreeError.Wrapf("orphan found"))
+		}
+
+		return true, nil
+	}); err != nil {
+		return ValidationReport{}, err
+	}
+
+	return report, nil
+}
+
+func (tv TreeValidator) report(
+	node Node, parents []Node, bound keyBound, visitor *nodeVisitor, report *ValidationReport,
+) error {
+	if err := visitor.enter(node); err != nil {
+		// NOTE the node was already visited under the other parent or it's
+		// ancestor; the subtree is skipped.
+		if len(parents) < 1 {
+			return err
+		}
+
+		report.add(violationKindOfVisit(err), parents[len(parents)-1], parents[:len(parents)-1], err)
+
+		return nil
+	}
+	defer visitor.exit(node)
+
+	if ancestor, err := bound.check(node); err != nil {
+		report.addWithAncestor(ViolationKeyOrder, node, parents, ancestor, err)
+	}
+
+	keys := [][]byte{node.LeftKey(), node.RightKey()}
+	if keys[0] != nil && EqualKey(keys[0], keys[1]) {
+		report.add(ViolationSharedNode, node, parents, SharedNodeError.Wrapf("same left and right; key=%x", keys[1]))
+		keys[1] = nil
+	}
+
+	for i, key := range keys {
+		if key == nil {
+			continue
+		}
+
+		if err := visitor.check(key); err != nil {
+			report.add(violationKindOfVisit(err), node, parents, err)
+			keys[i] = nil
+		}
+	}
+
+	leaves, err := GetNodes(tv.tr.NodePool(), keys)
+	if err != nil {
+		return err
+	}
+
+	for i, key := range keys {
+		if key != nil && leaves[i] == nil {
+			report.add(ViolationMissingChild, node, parents, InvalidTreeError.Wrapf("leaf not found: leaf=%x", key))
+		}
+	}
+
+	left, right := leaves[0], leaves[1]
+
+	for _, c := range []struct {
+		kind ViolationKind
+		f    func(Node, Node, Node) error
+	}{
+		{kind: ViolationKeyOrder, f: isValidNodeKey},
+		{kind: ViolationBalance, f: isValidNodeBalance},
+		{kind: ViolationHeight, f: isValidNodeHeight},
+	} {
+		if err := c.f(node, left, right); err != nil {
+			report.add(c.kind, node, parents, err)
+		}
+	}
+
+	for _, f := range tv.nodeValidators {
+		if err := f(node, left, right); err != nil {
+			report.add(ViolationNodeValidator, node, parents, err)
+		}
+	}
+
+	for i, leaf := range leaves {
+		if leaf == nil {
+			continue
+		}
+
+		if err := tv.report(leaf, append(parents, node), bound.leaf(node, i == 0), visitor, report); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// violationKindOfVisit returns the ViolationKind of the error from
+// nodeVisitor.
+func violationKindOfVisit(err error) ViolationKind {
+	if xerrors.Is(err, CyclicNodeError) {
+		return ViolationCycle
+	}
+
+	return ViolationSharedNode
+}
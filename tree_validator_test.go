@@ -0,0 +1,199 @@
+package avl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/xerrors"
+)
+
+type testTreeValidator struct {
+	suite.Suite
+}
+
+func (t *testTreeValidator) kinds(report ValidationReport) map[int][]ViolationKind {
+	kinds := map[int][]ViolationKind{}
+	for _, v := range report.Violations {
+		k := parseNodeIntKey(v.Key)
+		kinds[k] = append(kinds[k], v.Kind)
+	}
+
+	return kinds
+}
+
+func (t *testTreeValidator) TestValid() {
+	tr, err := treeFromShape(100, map[int]shape{
+		100: {height: 2, left: 50, right: 150},
+		50:  {height: 1, left: 30, right: 70},
+		150: {height: 0},
+		30:  {height: 0},
+		70:  {height: 0},
+	})
+	t.NoError(err)
+
+	report, err := NewTreeValidator(tr).Validate()
+	t.NoError(err)
+	t.True(report.IsValid())
+	t.NoError(report.Err())
+}
+
+func (t *testTreeValidator) TestReport() {
+	tr, err := treeFromShape(100, map[int]shape{
+		100: {height: 4, left: 50, right: 150},
+		50:  {height: 1, left: 70, right: 30}, // key order
+		150: {height: 3, right: 180},          // balance
+		180: {height: 2, left: 170, right: 200},
+		170: {height: 0},
+		200: {height: 1, right: 210},
+		210: {height: 0},
+		30:  {height: 0},
+		70:  {height: 0, left: 60}, // missing child
+		300: {height: 0},           // orphan
+	})
+	t.NoError(err)
+
+	// NOTE height violation
+	n, _ := tr.NodePool().Get(nodeIntKey(170))
+	n.(*ExampleNode).height = 1
+
+	report, err := NewTreeValidator(tr).Validate()
+	t.NoError(err)
+	t.False(report.IsValid())
+	t.True(xerrors.Is(report.Err(), InvalidTreeError))
+
+	kinds := t.kinds(report)
+	t.Equal(map[int][]ViolationKind{
+		100: {ViolationBalance},
+		50:  {ViolationKeyOrder},
+		30:  {ViolationKeyOrder},
+		150: {ViolationBalance},
+		170: {ViolationHeight},
+		70:  {ViolationKeyOrder, ViolationMissingChild},
+		300: {ViolationOrphan},
+	}, kinds)
+
+	for _, v := range report.Violations {
+		if parseNodeIntKey(v.Key) != 170 {
+			continue
+		}
+
+		t.Equal([][]byte{nodeIntKey(100), nodeIntKey(150), nodeIntKey(180)}, v.Path)
+	}
+}
+
+func (t *testTreeValidator) TestAncestorBound() {
+	// NOTE 120 is greater than 50, but it is in the left of 100.
+	tr, err := treeFromShape(100, map[int]shape{
+		100: {height: 2, left: 50, right: 150},
+		50:  {height: 1, right: 120},
+		120: {height: 0},
+		150: {height: 0},
+	})
+	t.NoError(err)
+
+	err = tr.IsValid()
+	t.True(xerrors.Is(err, InvalidNodeError))
+
+	report, err := NewTreeValidator(tr).Validate()
+	t.NoError(err)
+	t.Equal(1, len(report.Violations))
+
+	v := report.Violations[0]
+	t.Equal(ViolationKeyOrder, v.Kind)
+	t.Equal(nodeIntKey(120), v.Key)
+	t.Equal(nodeIntKey(100), v.Ancestor)
+	t.Equal([][]byte{nodeIntKey(100), nodeIntKey(50)}, v.Path)
+}
+
+func (t *testTreeValidator) TestCycle() {
+	tr, err := treeFromShape(100, map[int]shape{
+		100: {height: 2, left: 50},
+		50:  {height: 1, left: 30},
+		30:  {height: 0, left: 100},
+	})
+	t.NoError(err)
+
+	t.True(xerrors.Is(tr.IsValid(), CyclicNodeError))
+
+	report, err := NewTreeValidator(tr).Validate()
+	t.NoError(err)
+
+	kinds := t.kinds(report)
+	t.Contains(kinds[30], ViolationCycle)
+}
+
+func (t *testTreeValidator) TestSharedNode() {
+	tr, err := treeFromShape(100, map[int]shape{
+		100: {height: 2, left: 50, right: 150},
+		50:  {height: 1, right: 70},
+		150: {height: 1, left: 70},
+		70:  {height: 0},
+	})
+	t.NoError(err)
+
+	t.True(xerrors.Is(tr.IsValid(), SharedNodeError))
+
+	report, err := NewTreeValidator(tr).Validate()
+	t.NoError(err)
+
+	kinds := t.kinds(report)
+	t.Contains(kinds[150], ViolationSharedNode)
+}
+
+func (t *testTreeValidator) TestSharedNodeInSubtree() {
+	tr, err := treeFromShape(100, map[int]shape{
+		100: {height: 2, left: 50, right: 150},
+		50:  {height: 1, right: 150},
+		150: {height: 0},
+	})
+	t.NoError(err)
+
+	t.Error(tr.IsValid())
+
+	report, err := NewTreeValidator(tr).Validate()
+	t.NoError(err)
+
+	kinds := t.kinds(report)
+	t.Contains(kinds[100], ViolationSharedNode)
+	t.Contains(kinds[150], ViolationKeyOrder)
+}
+
+func (t *testTreeValidator) TestSameLeftAndRight() {
+	tr, err := treeFromShape(100, map[int]shape{
+		100: {height: 1, left: 50, right: 50},
+		50:  {height: 0},
+	})
+	t.NoError(err)
+
+	t.Error(tr.IsValid())
+
+	report, err := NewTreeValidator(tr).Validate()
+	t.NoError(err)
+
+	kinds := t.kinds(report)
+	t.Contains(kinds[100], ViolationSharedNode)
+}
+
+func (t *testTreeValidator) TestNodeValidator() {
+	tr, err := treeFromShape(100, map[int]shape{
+		100: {height: 1, left: 50, right: 150},
+		50:  {height: 0},
+		150: {height: 0},
+	})
+	t.NoError(err)
+
+	report, err := NewTreeValidator(tr).AddNodeValidator(func(node, _, _ Node) error {
+		if parseNodeIntKey(node.Key()) == 150 {
+			return xerrors.Errorf("findme")
+		}
+
+		return nil
+	}).Validate()
+	t.NoError(err)
+
+	t.Equal(map[int][]ViolationKind{150: {ViolationNodeValidator}}, t.kinds(report))
+}
+
+func TestTreeValidator(t *testing.T) {
+	suite.Run(t, new(testTreeValidator))
+}